@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// RegisterAlias declares, using the Default() Config, that oldKey is replaced by newKey. See Config.RegisterAlias
+func RegisterAlias(cmd *cobra.Command, oldKey, newKey string) error {
+	return Default().RegisterAlias(cmd, oldKey, newKey)
+}
+
+// RegisterAlias declares that oldKey is replaced by newKey, both prefixed by cmd (see PrefixKey).
+//
+// The old name keeps working everywhere, with a single warning naming the replacement when it is used:
+//   - in config files, the value of oldKey moves to newKey unless newKey is set in the same file
+//   - the environment variable of oldKey is read when the one of newKey is not set
+//   - if cmd has a flag named newKey, a hidden flag named oldKey sets it. Register the flags first
+//   - getters and Set with oldKey use newKey
+func (c *Config) RegisterAlias(cmd *cobra.Command, oldKey, newKey string) error {
+	oldFullKey := strings.ToLower(c.prefixKey(cmd, oldKey))
+	newFullKey := strings.ToLower(c.prefixKey(cmd, newKey))
+
+	if err := c.viper.BindEnv(
+		newFullKey,
+		c.envKey(nil, newFullKey),
+		c.envKey(nil, oldFullKey),
+	); err != nil {
+		return err
+	}
+	c.viper.RegisterAlias(oldFullKey, newFullKey)
+
+	a := &alias{newKey: newFullKey}
+	if cmd != nil {
+		for _, flags := range []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags()} {
+			newFlag := flags.Lookup(newKey)
+			if newFlag == nil || flags.Lookup(oldKey) != nil {
+				continue
+			}
+			a.newFlag = newFlag
+			a.oldFlag = &pflag.Flag{
+				Name:     oldKey,
+				Usage:    "Use --" + newKey,
+				Value:    newFlag.Value,
+				DefValue: newFlag.DefValue,
+				Hidden:   true,
+			}
+			flags.AddFlag(a.oldFlag)
+			break
+		}
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.aliases == nil {
+		c.aliases = make(map[string]*alias)
+	}
+	c.aliases[oldFullKey] = a
+	return nil
+}
+
+// alias is a deprecated key replaced by newKey
+type alias struct {
+	newKey  string
+	oldFlag *pflag.Flag
+	newFlag *pflag.Flag
+	warned  bool
+}
+
+// warn logs once that the deprecated key, used as old, is replaced by replacement
+func (a *alias) warn(old, replacement string) {
+	if a.warned {
+		return
+	}
+	a.warned = true
+	logger.Warnf("%s is deprecated, use '%s' instead", old, replacement)
+}
+
+// applyAliases forwards the deprecated flags to their replacement and warns about the deprecated env variables
+func (c *Config) applyAliases() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	for oldKey, a := range c.aliases {
+		if a.oldFlag != nil && a.oldFlag.Changed {
+			// both flags share the value, so the new one only needs to be seen as set
+			a.newFlag.Changed = true
+			a.warn("flag '--"+a.oldFlag.Name+"'", "--"+a.newFlag.Name)
+		}
+		if envKey := c.envKey(nil, oldKey); os.Getenv(envKey) != "" {
+			a.warn("environment variable '"+envKey+"'", c.envKey(nil, a.newKey))
+		}
+	}
+}
+
+// renameAliases moves, in the settings of a config layer, the values of the deprecated keys to their replacement
+func (c *Config) renameAliases(layer configLayer) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	for oldKey, a := range c.aliases {
+		value, ok := deleteSetting(layer.settings, oldKey)
+		if !ok {
+			continue
+		}
+		if settingAt(layer.settings, a.newKey) == nil {
+			setSetting(layer.settings, a.newKey, value)
+		}
+		a.warn("config key '"+oldKey+"' in '"+layer.source+"'", a.newKey)
+	}
+}
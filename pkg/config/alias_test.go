@@ -0,0 +1,59 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+type testAliasConfig struct {
+	RequestTimeout string `default:"1s"`
+}
+
+func TestRegisterAlias(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		args []string
+	}{
+		{name: "file", file: "foo:\n  timeout: 5s\n"},
+		{name: "file keeps new key", file: "foo:\n  timeout: 1m\n  request-timeout: 5s\n"},
+		{name: "env", env: "5s"},
+		{name: "flag", args: []string{"--timeout", "5s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.file != "" {
+				writeTestFile(t, filepath.Join(dir, "app.yaml"), tt.file)
+			}
+			if tt.env != "" {
+				t.Setenv("TEST_FOO_TIMEOUT", tt.env)
+			}
+			root := &cobra.Command{Use: "app"}
+			cmd := &cobra.Command{Use: "foo"}
+			root.AddCommand(cmd)
+
+			c := newSourceTestConfig(t, []string{dir}, WithConfigName("app"))
+			if err := c.RegisterFlags(cmd, &testAliasConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.RegisterAlias(cmd, "timeout", "request-timeout"); err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.InitConfig(); err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range []string{"request-timeout", "timeout"} {
+				if value := c.GetString(cmd, k); value != "5s" {
+					t.Errorf("Expected %s to be '5s', got '%s'", k, value)
+				}
+			}
+		})
+	}
+}
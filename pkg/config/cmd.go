@@ -0,0 +1,264 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/file"
+)
+
+// Output formats of the config subcommands
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// NewConfigCommand returns a `config` command grouping the config subcommands. If c is nil, Default() is used
+func NewConfigCommand(c *Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit the configuration",
+	}
+	cmd.AddCommand(
+		NewExplainCommand(c),
+		NewSchemaCommand(c),
+		NewInitCommand(c),
+		NewGetCommand(c),
+		NewSetCommand(c),
+		NewEnvCommand(c),
+	)
+	return cmd
+}
+
+// NewExplainCommand returns an `explain [key-prefix...]` command printing where each config value came from.
+// If c is nil, Default() is used
+func NewExplainCommand(c *Config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "explain [key-prefix...]",
+		Short: "Show the effective configuration and where each value came from",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if c == nil {
+				c = Default()
+			}
+			provenance := filterProvenance(c.Explain(), args)
+			switch output {
+			case OutputTable:
+				return writeProvenanceTable(cmd.OutOrStdout(), provenance)
+			case OutputJSON:
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(provenance)
+			}
+			return fmt.Errorf("invalid output format '%s'. Provide one of: %s, %s", output, OutputTable, OutputJSON)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", OutputTable,
+		fmt.Sprintf("Output format, one of: '%s, %s'", OutputTable, OutputJSON))
+	return cmd
+}
+
+// filterProvenance keeps the keys starting with any of prefixes. No prefixes keeps all
+func filterProvenance(provenance []Provenance, prefixes []string) []Provenance {
+	if len(prefixes) == 0 {
+		return provenance
+	}
+	filtered := make([]Provenance, 0, len(provenance))
+	for _, p := range provenance {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(p.Key, strings.ToLower(prefix)) {
+				filtered = append(filtered, p)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func writeProvenanceTable(w io.Writer, provenance []Provenance) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE\tDETAIL")
+	for _, p := range provenance {
+		fmt.Fprintf(tw, "%s\t%v\t%s\t%s\n", p.Key, p.Value, p.Source, p.Detail)
+	}
+	return tw.Flush()
+}
+
+// NewSchemaCommand returns a `schema` command printing the JSON Schema of the configuration.
+// If c is nil, Default() is used
+func NewSchemaCommand(c *Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if c == nil {
+				c = Default()
+			}
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(c.JSONSchema())
+		},
+	}
+}
+
+// NewInitCommand returns an `init` command writing the user config file with every known key and its default.
+// If c is nil, Default() is used
+func NewInitCommand(c *Config) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the user config file with the default values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if c == nil {
+				c = Default()
+			}
+			if c.Options() == nil {
+				return errors.New("cannot write the user config without Options, see Config.Use")
+			}
+			configFile, err := c.Options().UserConfigFile()
+			if err != nil {
+				return err
+			}
+			if file.IsFile(configFile) && !force {
+				return fmt.Errorf("config file '%s' already exists, use --force to overwrite it", configFile)
+			}
+			if err = writeConfigFile(configFile, c.Defaults(), false); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), configFile)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite the existing config file")
+	return cmd
+}
+
+// NewGetCommand returns a `get <key>` command printing the effective value of a key. If c is nil, Default() is used
+func NewGetCommand(c *Config) *cobra.Command {
+	var (
+		commandPath string
+		reveal      bool
+	)
+	cmd := &cobra.Command{
+		Use:               "get <key>",
+		Short:             "Print the effective value of a config key",
+		ValidArgsFunction: completeKeys(&c),
+		Args:              cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if c == nil {
+				c = Default()
+			}
+			key, err := c.scopedKey(cmd, commandPath, args[0])
+			if err != nil {
+				return err
+			}
+			value := c.effectiveValue(key, reveal)
+			if value == nil {
+				return fmt.Errorf("key '%s' is not set", key)
+			}
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(value)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&commandPath, "command", "c", "",
+		"Space separated path of the command the key belongs to, for example 'deploy'")
+	cmd.Flags().BoolVar(&reveal, "reveal", false, "Print secret values instead of masking them")
+	return cmd
+}
+
+// NewSetCommand returns a `set <key> <value>` command persisting a value into the user config file.
+// If c is nil, Default() is used
+func NewSetCommand(c *Config) *cobra.Command {
+	var commandPath string
+	cmd := &cobra.Command{
+		Use:               "set <key> <value>",
+		Short:             "Persist a config value into the user config file",
+		ValidArgsFunction: completeKeys(&c),
+		Args:              cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if c == nil {
+				c = Default()
+			}
+			key, err := c.scopedKey(cmd, commandPath, args[0])
+			if err != nil {
+				return err
+			}
+			value, err := c.ParseValue(key, args[1])
+			if err != nil {
+				return fmt.Errorf("invalid value for '%s': %w", key, err)
+			}
+			return c.WriteUserConfig(map[string]interface{}{key: value})
+		},
+	}
+	cmd.Flags().StringVarP(&commandPath, "command", "c", "",
+		"Space separated path of the command the key belongs to, for example 'deploy'")
+	return cmd
+}
+
+// effectiveValue returns the value of the dotted key, a leaf or a section, including the defaults of flags.
+// Secret values are masked unless reveal is true. The Options flags not bound to viper give their own value
+func (c *Config) effectiveValue(key string, reveal bool) interface{} {
+	c.mu.RLock()
+	settings := c.effectiveSettings()
+	if reveal {
+		settings = c.viper.AllSettings()
+	}
+	c.mu.RUnlock()
+	if value := settingAt(settings, key); value != nil {
+		return value
+	}
+	if c.opts != nil && c.opts.Flags != nil {
+		if flag := c.opts.Flags.Lookup(key); flag != nil {
+			return flag.Value.String()
+		}
+	}
+	return nil
+}
+
+// scopedKey prefixes key with the command found at commandPath from the root of cmd, see PrefixKey
+func (c *Config) scopedKey(cmd *cobra.Command, commandPath, key string) (string, error) {
+	if commandPath == "" {
+		return strings.ToLower(key), nil
+	}
+	scope, _, err := cmd.Root().Find(strings.Fields(commandPath))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(c.prefixKey(scope, key)), nil
+}
+
+// settingAt returns the value at the dotted key of nested settings
+func settingAt(settings map[string]interface{}, key string) interface{} {
+	var value interface{} = settings
+	for _, segment := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[segment]
+	}
+	return value
+}
+
+// completeKeys returns a CompletionFunc calling CompleteKeys on *c, or on Default() if nil
+func completeKeys(c **Config) CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if *c == nil {
+			return Default().CompleteKeys(cmd, args, toComplete)
+		}
+		return (*c).CompleteKeys(cmd, args, toComplete)
+	}
+}
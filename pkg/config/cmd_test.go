@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfigCommands(t *testing.T) {
+	t.Setenv("TEST_PASSWORD", "s3cret")
+	appHome := t.TempDir()
+	c := newSourceTestConfig(t, []string{appHome}, WithAppHome(appHome))
+	configFile, err := c.Options().UserConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, configFile, "server:\n  port: 8080\npassword: ${env:TEST_PASSWORD}\n")
+	root, deploy, _ := newKeyTestCommands()
+	if err = c.RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+		check    func(t *testing.T, out string)
+	}{
+		{name: "get flag default", args: []string{"get", "log-level"}, expected: "warn\n"},
+		{name: "get file value", args: []string{"get", "server.port"}, expected: "8080\n"},
+		{name: "get section", args: []string{"get", "server"}, expected: "{\n  \"port\": 8080\n}\n"},
+		{name: "get command flag", args: []string{"get", "-c", "deploy", "request-timeout"}, expected: "1s\n"},
+		{name: "get secret", args: []string{"get", "password"}, expected: SecretMask + "\n"},
+		{name: "get revealed secret", args: []string{"get", "--reveal", "password"}, expected: "s3cret\n"},
+		{name: "get unknown", args: []string{"get", "missing"}, err: "key 'missing' is not set"},
+		{name: "explain table", args: []string{"explain", "server"}, check: func(t *testing.T, out string) {
+			if fields := strings.Fields(strings.Split(out, "\n")[1]); len(fields) != 4 ||
+				fields[0] != "server.port" || fields[1] != "8080" || fields[2] != SourceFile {
+				t.Errorf("Unexpected explain table '%s'", out)
+			}
+		}},
+		{name: "explain json", args: []string{"explain", "-o", "json", "password"}, check: func(t *testing.T, out string) {
+			var provenance []Provenance
+			if err := json.Unmarshal([]byte(out), &provenance); err != nil {
+				t.Fatal(err)
+			}
+			if len(provenance) != 1 || provenance[0].Value != SecretMask {
+				t.Errorf("Expected the masked password, got %+v", provenance)
+			}
+		}},
+		{name: "explain invalid output", args: []string{"explain", "-o", "xml"}, err: "invalid output format 'xml'"},
+		{name: "schema", args: []string{"schema"}, check: func(t *testing.T, out string) {
+			var schema Schema
+			if err := json.Unmarshal([]byte(out), &schema); err != nil {
+				t.Fatal(err)
+			}
+			if schema.Schema != SchemaDraft || schema.Properties["deploy"] == nil {
+				t.Errorf("Unexpected schema '%s'", out)
+			}
+		}},
+		{name: "set", args: []string{"set", "-c", "deploy", "request-timeout", "5s"}},
+		{name: "get set value", args: []string{"get", "deploy.request-timeout"}, expected: "5s\n"},
+		{name: "init existing", args: []string{"init"}, err: "already exists"},
+		{name: "init force", args: []string{"init", "--force"}, expected: configFile + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a new command, as flag values stick between executions
+			configCmd := NewConfigCommand(c)
+			root.AddCommand(configCmd)
+			defer root.RemoveCommand(configCmd)
+			var out bytes.Buffer
+			root.SetOut(&out)
+			root.SetErr(&out)
+			root.SetArgs(append([]string{"config"}, tt.args...))
+			err := root.Execute()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.check != nil {
+				tt.check(t, out.String())
+			} else if out.String() != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, out.String())
+			}
+		})
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "request-timeout: 1s") || strings.Contains(string(content), "port") {
+		t.Errorf("Expected init to write the defaults, got '%s'", content)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// CompletionFunc is the signature of cobra flag and argument completion functions
+type CompletionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// CompleteValues returns a CompletionFunc offering the values returned by values, evaluated on every completion
+func CompleteValues(values func() []string) CompletionFunc {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return filterPrefix(values(), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// RegisterCompletions registers shell completion on cmd for the flags of Options.Flags:
+// the log levels for LogLevelKey, the log formats for LogFormatKey.
+// cmd must already have the flags, usually added with cmd.PersistentFlags().AddFlagSet(opts.Flags).
+// The --config flag completes files with the supported extensions out of the box
+func (opts *Options) RegisterCompletions(cmd *cobra.Command) error {
+	completions := map[string]CompletionFunc{
+		opts.LogLevelKey:  CompleteValues(func() []string { return log.AllLevelsValues }),
+		opts.LogFormatKey: CompleteValues(func() []string { return log.Formats() }),
+	}
+	for name, fn := range completions {
+		if cmd.Flag(name) == nil {
+			continue
+		}
+		if err := cmd.RegisterFlagCompletionFunc(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteKeys is a CompletionFunc offering the config keys known to c, from config files, flags, defaults
+// and registered structs. If the command has a --command flag, as `config get` does,
+// keys are offered relative to the command it names, see PrefixKey
+func (c *Config) CompleteKeys(
+	cmd *cobra.Command, args []string, toComplete string,
+) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	prefix := ""
+	if commandPath, err := cmd.Flags().GetString("command"); err == nil && commandPath != "" {
+		scope, err := c.scopedKey(cmd, commandPath, "")
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		prefix = stringutil.ConcatStrings(scope, c.opts.keySeparator())
+	}
+	keys := make([]string, 0)
+	for _, k := range c.Keys() {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, strings.TrimPrefix(k, prefix))
+		}
+	}
+	return filterPrefix(keys, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// Keys returns the sorted, unique keys known to c: set in any layer or declared by JSONSchema
+func (c *Config) Keys() []string {
+	seen := make(map[string]bool)
+	c.mu.RLock()
+	for _, k := range c.viper.AllKeys() {
+		seen[k] = true
+	}
+	c.mu.RUnlock()
+	for k := range c.Defaults() {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// filterPrefix returns the values starting with prefix
+func filterPrefix(values []string, prefix string) []string {
+	filtered := make([]string, 0, len(values))
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+func TestCompleteValues(t *testing.T) {
+	complete := CompleteValues(func() []string { return []string{"fast", "faster", "slow"} })
+	tests := []struct {
+		toComplete string
+		expected   []string
+	}{
+		{"", []string{"fast", "faster", "slow"}},
+		{"fast", []string{"fast", "faster"}},
+		{"s", []string{"slow"}},
+		{"x", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.toComplete, func(t *testing.T) {
+			values, directive := complete(nil, nil, tt.toComplete)
+			if strings.Join(values, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, values)
+			}
+			if directive != cobra.ShellCompDirectiveNoFileComp {
+				t.Errorf("Expected no file completion, got %d", directive)
+			}
+		})
+	}
+}
+
+func TestRegisterCompletions(t *testing.T) {
+	c := newSourceTestConfig(t, nil)
+	root, deploy, _ := newKeyTestCommands()
+	root.ResetFlags()
+	root.PersistentFlags().AddFlagSet(c.Options().Flags)
+	if err := c.Options().RegisterCompletions(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterFlags(deploy, &testSchemaConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cmd        *cobra.Command
+		flag       string
+		toComplete string
+		expected   []string
+	}{
+		{root, "log-level", "w", []string{log.WarnLevel.String()}},
+		{root, "log-format", "log", []string{log.FormatLogfmt}},
+		{deploy, "mode", "", []string{"fast", "slow"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			complete, ok := tt.cmd.GetFlagCompletionFunc(tt.flag)
+			if !ok {
+				t.Fatalf("Expected a completion for '%s'", tt.flag)
+			}
+			values, _ := complete(tt.cmd, nil, tt.toComplete)
+			if strings.Join(values, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, values)
+			}
+		})
+	}
+}
+
+func TestCompleteKeys(t *testing.T) {
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "server:\n  port: 8080\n")
+	c := newSourceTestConfig(t, []string{path})
+	root, deploy, _ := newKeyTestCommands()
+	if err := c.RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		toComplete string
+		expected   []string
+		directive  cobra.ShellCompDirective
+	}{
+		{name: "file key", toComplete: "ser", expected: []string{"server.port"}},
+		{name: "flag key", toComplete: "deploy.", expected: []string{"deploy.request-timeout"}},
+		{name: "command scope", command: "deploy", toComplete: "req", expected: []string{"request-timeout"}},
+		{name: "second argument", args: []string{"server.port"}},
+		{name: "unknown command", command: "missing", directive: cobra.ShellCompDirectiveError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configCmd := NewConfigCommand(c)
+			root.AddCommand(configCmd)
+			defer root.RemoveCommand(configCmd)
+			getCmd, _, err := root.Find([]string{"config", "get"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = getCmd.Flags().Set("command", tt.command); err != nil {
+				t.Fatal(err)
+			}
+			keys, directive := c.CompleteKeys(getCmd, tt.args, tt.toComplete)
+			if strings.Join(keys, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, keys)
+			}
+			if tt.directive == 0 {
+				tt.directive = cobra.ShellCompDirectiveNoFileComp
+			}
+			if directive != tt.directive {
+				t.Errorf("Expected directive %d, got %d", tt.directive, directive)
+			}
+		})
+	}
+}
@@ -1,14 +1,16 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
-	jww "github.com/spf13/jwalterweatherman"
+	"github.com/fsnotify/fsnotify"
 	"github.com/thedataflows/go-commons/pkg/defaults"
 	"github.com/thedataflows/go-commons/pkg/file"
 	"github.com/thedataflows/go-commons/pkg/lang"
@@ -22,15 +24,53 @@ import (
 )
 
 type Options struct {
-	EnvPrefix       string
-	ConfigType      string
-	ConfigName      string
+	EnvPrefix  string
+	ConfigType string
+	ConfigName string
+	// AppHome is the directory where the user config file is written, see UserConfigFile
+	AppHome         string
 	UserConfigPaths []string
 	LogLevel        string
 	LogLevelKey     string
 	LogFormat       string
 	LogFormatKey    string
-	Flags           *pflag.FlagSet
+	// LogFile writes the log to a rotating file as well, see WithLogFile
+	LogFile log.FileOptions
+	// LogStderr keeps writing the log to stderr when LogFile is set
+	LogStderr bool
+	// SlogDefault installs log.SetSlogDefault in InitConfig, see WithSlogDefault
+	SlogDefault bool
+	// Profile is the name of the profile overlaid on top of the base config, see WithProfile
+	Profile    string
+	ProfileKey string
+	Flags      *pflag.FlagSet
+	// WatchConfig enables reloading the configuration when any of the config files changes
+	WatchConfig bool
+	// Resolvers replace ${scheme:ref} references in config file values, keyed by scheme
+	Resolvers map[string]Resolver
+	// SchemaValidation validates the config files against JSONSchema when loading them
+	SchemaValidation SchemaValidation
+	// SourceOpeners read UserConfigPaths entries that are not local paths, keyed by scheme, see Source
+	SourceOpeners map[string]SourceOpener
+	// SourceTimeout bounds the time a single Source may take to read
+	SourceTimeout time.Duration
+	// ResolverTimeout bounds the time a single ${scheme:ref} reference may take to resolve
+	ResolverTimeout time.Duration
+	// EnvFiles are .env files loaded into the environment before reading it, see WithEnvFiles
+	EnvFiles []string
+	// Migrations upgrade config files written for older versions, see WithMigration
+	Migrations []Migration
+	// MigrationRewrite rewrites migrated config files in place
+	MigrationRewrite bool
+	// Strict fails loading on config paths that are not accessible and on files that cannot be parsed, see WithStrict
+	Strict bool
+	// KeySeparator joins the command names and the key in PrefixKey, "." when empty
+	KeySeparator string
+	// UnprefixedRootFlags keeps the keys of the root persistent flags unprefixed in subcommands, see PrefixKey
+	UnprefixedRootFlags bool
+
+	mu       sync.Mutex
+	onChange []ChangeFunc
 }
 
 type Option func(*Options)
@@ -53,6 +93,12 @@ func WithConfigName(configName string) Option {
 	}
 }
 
+func WithAppHome(appHome string) Option {
+	return func(o *Options) {
+		o.AppHome = appHome
+	}
+}
+
 func WithUserConfigPaths(userConfigPaths []string) Option {
 	userConfigPaths = lang.UniqueSliceElements(userConfigPaths)
 	return func(o *Options) {
@@ -72,124 +118,293 @@ func WithLogFormatKey(logFormatKey string) Option {
 	}
 }
 
+// WithLogFile writes the log to a rotating file described by fileOptions, and to stderr if stderr is true.
+// The config keys `log-file`, `log-file-max-size` (bytes, like "10mb"), `log-file-max-age`, `log-file-max-backups`,
+// `log-file-compress` and `log-stderr` override them
+func WithLogFile(fileOptions log.FileOptions, stderr bool) Option {
+	return func(o *Options) {
+		o.LogFile = fileOptions
+		o.LogStderr = stderr
+	}
+}
+
+// WithSlogDefault makes InitConfig install a handler writing to the log package as slog.Default(),
+// so libraries using log/slog, and the standard log package, share the configured level and format
+func WithSlogDefault(slogDefault bool) Option {
+	return func(o *Options) {
+		o.SlogDefault = slogDefault
+	}
+}
+
 func WithFlags(flags *pflag.FlagSet) Option {
 	return func(o *Options) {
 		o.Flags = flags
 	}
 }
 
-// NewOptions sets default Options overriding with options
+func WithWatchConfig(watchConfig bool) Option {
+	return func(o *Options) {
+		o.WatchConfig = watchConfig
+	}
+}
+
+func WithOnChange(fn ChangeFunc) Option {
+	return func(o *Options) {
+		o.onChange = append(o.onChange, fn)
+	}
+}
+
+// NewOptions sets default Options overriding with options.
+// They are used by the Default() Config once passed to Options.InitConfig or Config.Use
 func NewOptions(options ...Option) (*Options, error) {
 	opts := Options{
-		EnvPrefix: defaults.ViperEnvPrefix,
+		EnvPrefix:       defaults.ViperEnvPrefix,
+		Resolvers:       DefaultResolvers(),
+		SourceOpeners:   DefaultSourceOpeners(),
+		SourceTimeout:   DefaultSourceTimeout,
+		ResolverTimeout: DefaultResolverTimeout,
 	}
 	opts.ConfigName = file.TrimExtension(filepath.Base(process.CurrentProcessPath()))
-	configPath, err := file.AppHome("")
+	var err error
+	opts.AppHome, err = file.AppHome("")
 	if err != nil {
 		return nil, err
 	}
-	opts.UserConfigPaths = []string{".", configPath}
+	opts.UserConfigPaths = xdgConfigPaths(opts.ConfigName)
+	opts.UserConfigPaths = append(opts.UserConfigPaths, ".", opts.AppHome)
 	opts.LogLevelKey = defaults.LogLevelKey
 	opts.LogFormatKey = defaults.LogFormatKey
+	opts.ProfileKey = defaults.ProfileKey
+	opts.LogFile = log.FileOptions{MaxSize: 100 << 20, MaxBackups: 5, Compress: true}
+	opts.LogStderr = true
 
 	opts.Flags = pflag.NewFlagSet("root", pflag.ExitOnError)
 	opts.Flags.StringVar(
 		&opts.LogLevel,
 		opts.LogLevelKey,
 		log.WarnLevel.String(),
-		fmt.Sprintf("Set log level to one of: '%s'",
+		fmt.Sprintf("Set log level to one of: '%s'. "+
+			"Comma separated '<component>=<level>' items set the level of components, like 'warn,config=debug'",
 			strings.Join(log.AllLevelsValues, ", "),
 		),
 	)
 	opts.Flags.StringVar(
 		&opts.LogFormat,
 		opts.LogFormatKey,
-		log.LogFormats[0],
-		fmt.Sprintf("Set log format to one of: '%s'", strings.Join(log.LogFormats, ", ")),
+		log.Formats()[0],
+		fmt.Sprintf("Set log format to one of: '%s'", strings.Join(log.Formats(), ", ")),
+	)
+	opts.Flags.StringVar(
+		&opts.LogFile.Path,
+		defaults.LogFileKey,
+		"",
+		fmt.Sprintf("Also write the log to this file, rotated according to the '%s-*' config keys", defaults.LogFileKey),
+	)
+	opts.Flags.Var(
+		&sizeValue{size: &opts.LogFile.MaxSize},
+		defaults.LogFileMaxSizeKey,
+		"Rotate the log file when it would exceed this size, like '100MB', 0 for no limit",
+	)
+	opts.Flags.DurationVar(
+		&opts.LogFile.MaxAge,
+		defaults.LogFileMaxAgeKey,
+		opts.LogFile.MaxAge,
+		"Rotate the log file when it gets older than this duration, 0 for no limit",
+	)
+	opts.Flags.IntVar(
+		&opts.LogFile.MaxBackups,
+		defaults.LogFileMaxBackupsKey,
+		opts.LogFile.MaxBackups,
+		"Number of rotated log files to keep, 0 to keep them all",
+	)
+	opts.Flags.BoolVar(
+		&opts.LogFile.Compress,
+		defaults.LogFileCompressKey,
+		opts.LogFile.Compress,
+		"Compress the rotated log files with gzip",
+	)
+	opts.Flags.BoolVar(
+		&opts.LogStderr,
+		defaults.LogStderrKey,
+		opts.LogStderr,
+		"Write the log to stderr, also when it is written to a file",
+	)
+	opts.Flags.StringVar(
+		&opts.Profile,
+		opts.ProfileKey,
+		"",
+		fmt.Sprintf(
+			"Config profile to overlay on top of the base config, from files '%s.<profile>.<ext>' or section '%s.<profile>'",
+			opts.ConfigName,
+			defaults.ProfilesKey,
+		),
 	)
 	opts.Flags.StringSliceVar(
 		&opts.UserConfigPaths,
 		"config",
 		opts.UserConfigPaths,
 		fmt.Sprintf(
-			"Config file(s) or directories. When just dirs, file '%s' with extensions '%s' is looked up. Can be specified multiple times",
+			"Config file(s), directories or sources ('-' for stdin, http(s) URLs or registered sources, "+
+				"optionally prefixed by '<format>+'). "+
+				"When just dirs, file '%s' with extensions '%s' is looked up. Can be specified multiple times",
 			opts.ConfigName,
 			strings.Join(viper.SupportedExts, ", "),
 		),
 	)
 
+	_ = opts.Flags.SetAnnotation("config", cobra.BashCompFilenameExt, viper.SupportedExts)
+
 	// override defaults with options
 	for _, o := range options {
 		o(&opts)
 	}
 
+	// the package level functions, like RegisterFlags, use the first Options until Options.InitConfig is called
 	return &opts, nil
 }
 
-// InitConfig reads in config file and ENV variables if set.
-func (opts *Options) InitConfig() error {
+// Config is a configuration built from Options and backed by its own viper instance
+type Config struct {
+	opts  *Options
+	viper *viper.Viper
+
+	// mu guards viper against reloads: readers take the read lock, reload and Set the write lock
+	mu      sync.RWMutex
+	watcher *fsnotify.Watcher
+
+	// stateMu guards what is recorded while loading and binding.
+	// secrets holds the keys whose values were resolved from a reference, see IsSecret
+	stateMu   sync.RWMutex
+	secrets   map[string]bool
+	origins   map[string]string
+	bindings  map[string]*pflag.Flag
+	overrides map[string]bool
+	// aliases holds the deprecated full keys with their replacement
+	aliases map[string]*alias
+	// schemaFields holds the registered config struct fields by full key
+	schemaFields map[string]reflect.StructField
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultConfig = &Config{viper: newViper()}
+)
+
+// NewConfig returns a Config with its own viper instance. If opts is nil, NewOptions() defaults are used
+func NewConfig(opts *Options) (*Config, error) {
 	var err error
 	if opts == nil {
 		opts, err = NewOptions()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Config{
+		opts:  opts,
+		viper: newViper(),
+	}, nil
+}
+
+// Default returns the Config used by the package level functions. It has its own viper instance, see Config.Viper,
+// rather than viper's global one
+func Default() *Config {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultConfig
+}
+
+// SetDefault replaces the Config used by the package level functions
+func SetDefault(c *Config) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultConfig = c
+}
+
+// Use sets the Options of c. Call it on Default() before registering flags with the package level functions,
+// so their usage names the env variables of opts; Options.InitConfig calls it otherwise
+func (c *Config) Use(opts *Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts = opts
+}
+
+// Viper returns the underlying viper instance. Its reads are not guarded against config reloads,
+// prefer the getters of Config when watching config files
+func (c *Config) Viper() *viper.Viper {
+	return c.viper
+}
+
+// Options returns the Options this Config was built from
+func (c *Config) Options() *Options {
+	return c.opts
+}
+
+// InitConfig reads in config file and ENV variables if set, into the Default() Config.
+func (opts *Options) InitConfig() error {
+	c := Default()
+	c.Use(opts)
+	return c.InitConfig()
+}
+
+// InitConfig reads in config file and ENV variables if set.
+func (c *Config) InitConfig() error {
+	var err error
+	if c.opts == nil {
+		c.opts, err = NewOptions()
 		if err != nil {
 			return err
 		}
 	}
 
-	viper.SetEnvPrefix(opts.EnvPrefix)
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
-	viper.AutomaticEnv() // read in environment variables that match
+	if err = c.opts.loadEnvFiles(); err != nil {
+		return err
+	}
+	c.viper.SetEnvPrefix(c.opts.EnvPrefix)
+	c.viper.SetEnvKeyReplacer(c.opts.envKeyReplacer())
+	c.viper.AutomaticEnv() // read in environment variables that match
 
-	if err = opts.setLogging(); err != nil {
+	if err = c.setLogging(); err != nil {
 		return err
 	}
 
-	viper.SetConfigType(opts.ConfigType)
-	// Use config file from the flag.
-	for _, p := range opts.UserConfigPaths {
-		viper.SetConfigName(opts.ConfigName)
-		if !file.IsAccessible(p) {
-			log.Warnf("'%s' is not accessible!", p)
-			continue
-		}
-		if file.IsFile(p) {
-			viper.SetConfigName(file.TrimExtension(filepath.Base(p)))
-			p = filepath.Dir(p)
-		}
-		viper.AddConfigPath(p)
-		if err := viper.MergeInConfig(); err != nil {
-			log.Debugf("%s", err)
-		}
+	cfg, err := c.loadConfigFiles()
+	if err != nil {
+		return err
+	}
+	if err = c.viper.MergeConfigMap(cfg); err != nil {
+		return err
 	}
 
 	// a second call is to set again logging if configured in file
-	if err = opts.setLogging(); err != nil {
+	if err = c.setLogging(); err != nil {
 		return err
 	}
+	if c.opts.SlogDefault {
+		log.SetSlogDefault()
+	}
+	c.applyAliases()
 
-	if log.GetLevel() == log.TraceLevel {
-		log.Trace("====== begin viper configuration dump ======")
-		viper.DebugTo(log.Log.GetLogger())
-		time.Sleep(100 * time.Millisecond)
-		log.Trace("====== end viper configuration dump ======")
+	if logger.GetLogger().GetLevel() == log.TraceLevel {
+		// a single event, so every log format encodes it. Secret values are masked by key, see Explain
+		var dump bytes.Buffer
+		if err = writeProvenanceTable(&dump, c.Explain()); err != nil {
+			return err
+		}
+		logger.Tracef("viper configuration dump:\n%s", dump.String())
 	}
 
-	// TODO maybe enable WatchConfig() if finding a method to override the viper Logger with ours
-	// Perhaps via the Options interface?
-	// Option configures Viper using the functional options paradigm popularized by Rob Pike and Dave Cheney.
-	// If you're unfamiliar with this style,
-	// see https://commandcenter.blogspot.com/2014/01/self-referential-functions-and-design.html and
-	// https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis.
-	// viper.WatchConfig()
+	if c.opts.WatchConfig {
+		return c.watchConfig()
+	}
 
 	return nil
 }
 
-func (opts *Options) setLogging() error {
+func (c *Config) setLogging() error {
 	// Set log format
-	v := viper.GetString(opts.LogFormatKey)
+	v := c.viper.GetString(c.opts.LogFormatKey)
 	if len(v) == 0 {
-		v = opts.LogFormat
+		v = c.opts.LogFormat
 	}
 	err := log.SetLogFormat(v)
 	if err != nil {
@@ -197,188 +412,295 @@ func (opts *Options) setLogging() error {
 	}
 
 	// Set log level
-	v = viper.GetString(opts.LogLevelKey)
+	v = c.viper.GetString(c.opts.LogLevelKey)
 	if len(v) == 0 {
-		v = opts.LogLevel
+		v = c.opts.LogLevel
 	}
-	err = log.SetLogLevel(v)
-	if err != nil {
+	if err = log.SetLogLevel(v); err != nil {
 		return err
 	}
-	// Enable viper logging but only for debug and trace
-	switch log.GetLevel() {
-	case log.TraceLevel:
-		jww.SetLogThreshold(jww.LevelTrace)
-		jww.SetStdoutThreshold(jww.LevelTrace)
-	case log.DebugLevel:
-		jww.SetLogThreshold(jww.LevelDebug)
-		jww.SetStdoutThreshold(jww.LevelDebug)
-	}
-	jww.SetStdoutOutput(os.Stderr)
 
-	return nil
+	return log.SetLogFile(c.logFileOptions())
 }
 
-// CheckRequiredFlags exits with error when one ore more required flags are not set
-func CheckRequiredFlags(cmd *cobra.Command, requiredFlags []string) error {
-	neededFlags := make([]string, 0, len(requiredFlags))
-	for _, f := range requiredFlags {
-		if !ViperIsSet(cmd, f) {
-			neededFlags = append(neededFlags, f)
-		}
+// logFileOptions returns the LogFile and LogStderr options overridden by their config keys
+func (c *Config) logFileOptions() (log.FileOptions, bool) {
+	fileOptions := c.opts.LogFile
+	if v := c.viper.GetString(defaults.LogFileKey); len(v) > 0 {
+		fileOptions.Path = v
 	}
-
-	if len(neededFlags) > 0 {
-		errorMessage := "required flags are not set:"
-		for _, f := range neededFlags {
-			errorMessage = fmt.Sprintf("%s\n  --%s", errorMessage, f)
-		}
-		errorMessage = fmt.Sprintf("%s\n", errorMessage)
-		return fmt.Errorf(errorMessage)
+	if c.viper.IsSet(defaults.LogFileMaxSizeKey) {
+		fileOptions.MaxSize = int64(c.viper.GetSizeInBytes(defaults.LogFileMaxSizeKey))
 	}
-
-	return nil
-}
-
-// BuildEnvKey returns a fully constructed environment variable name
-func BuildEnvKey(cmd *cobra.Command, envPrefix string, keyName string) string {
-	if len(envPrefix) == 0 {
-		envPrefix = defaults.ViperEnvPrefix
+	if c.viper.IsSet(defaults.LogFileMaxAgeKey) {
+		fileOptions.MaxAge = c.viper.GetDuration(defaults.LogFileMaxAgeKey)
 	}
-	parentKey := stringutil.ConcatStrings(envPrefix, "_")
-
-	for cmd != nil && cmd != cmd.Root() {
-		parentKey = stringutil.ConcatStrings(cmd.Use, "_", parentKey)
-		cmd = cmd.Parent()
+	if c.viper.IsSet(defaults.LogFileMaxBackupsKey) {
+		fileOptions.MaxBackups = c.viper.GetInt(defaults.LogFileMaxBackupsKey)
 	}
-	if keyName == "" && parentKey[len(parentKey)-1:] == "_" {
-		return parentKey[:len(parentKey)-1]
+	if c.viper.IsSet(defaults.LogFileCompressKey) {
+		fileOptions.Compress = c.viper.GetBool(defaults.LogFileCompressKey)
 	}
-	return strings.ToUpper(parentKey + keyName)
-}
-
-// PrefixKey prepends current and parent Use to specified key name
-func PrefixKey(cmd *cobra.Command, keyName string) string {
-	parentKey := ""
-	for cmd != nil && cmd != cmd.Root() {
-		parentKey = stringutil.ConcatStrings(cmd.Use, ".", parentKey)
-		cmd = cmd.Parent()
+	stderr := c.opts.LogStderr
+	if c.viper.IsSet(defaults.LogStderrKey) {
+		stderr = c.viper.GetBool(defaults.LogStderrKey)
 	}
-	if keyName == "" && parentKey[len(parentKey)-1:] == "." {
-		return parentKey[:len(parentKey)-1]
-	}
-	return parentKey + keyName
+	return fileOptions, stderr
 }
 
-// AppendStringArgsf appends viper value to existing args slice with optional formatted output with key and value
-func AppendStringArgsf(format string, cmd *cobra.Command, args []string, key string) []string {
-	val := ViperGetString(cmd, key)
-	if val != "" {
-		args = append(args, fmt.Sprintf(format, key, val))
+// xdgConfigPaths returns the existing program directories in the XDG system config dirs, least preferred first,
+// so the most preferred one is merged last
+func xdgConfigPaths(programName string) []string {
+	dirs := file.ConfigDirs()
+	paths := make([]string, 0, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if p := filepath.Join(dirs[i], programName); file.IsDirectory(p) {
+			paths = append(paths, p)
+		}
 	}
-	return args
+	return paths
 }
 
-// AppendStringArgs appends viper value to existing args slice
-func AppendStringArgs(cmd *cobra.Command, args []string, key string) []string {
-	return AppendStringArgsf("", cmd, args, key)
+// configLayer holds the settings read from one config source, like a file
+type configLayer struct {
+	source   string
+	settings map[string]interface{}
+	// untrusted is set on layers read from a Source, whose references are not resolved
+	untrusted bool
 }
 
-// AppendSplitArgs appends viper value to existing args slice after splitting them by splitPattern (default regex whitespace)
-func AppendStringSplitArgs(cmd *cobra.Command, args []string, key string, splitPattern string) []string {
-	if splitPattern == "" {
-		splitPattern = `\s+`
+// loadConfigFiles resolves the references in the values of all config layers, merges them in order
+// and records which layer each key came from
+func (c *Config) loadConfigFiles() (map[string]interface{}, error) {
+	layers, err := c.opts.readConfigLayers()
+	if err != nil {
+		return nil, err
+	}
+	merged := newViper()
+	origins := make(map[string]string)
+	secrets := make(map[string]bool)
+	for _, layer := range layers {
+		c.renameAliases(layer)
+		layerSecrets, err := c.opts.resolveLayerReferences(layer)
+		if err != nil {
+			return nil, err
+		}
+		for k := range flattenSettings(layer.settings) {
+			origins[k] = layer.source
+			forgetSecret(secrets, k)
+		}
+		for k := range layerSecrets {
+			secrets[k] = true
+		}
+		if err = merged.MergeConfigMap(layer.settings); err != nil {
+			return nil, err
+		}
+	}
+	cfg := merged.AllSettings()
+	if err = c.checkSchema(cfg, origins); err != nil {
+		return nil, err
+	}
+	c.stateMu.Lock()
+	c.secrets = secrets
+	c.origins = origins
+	c.stateMu.Unlock()
+	return cfg, nil
+}
+
+// readConfigLayers reads, in order, the config files found in UserConfigPaths.
+// Each config file is followed by the files of its `<ConfigName>.d` drop-in directory, in lexical order,
+// and preceded by the files it includes, see readIncludeLayers.
+// Paths that are not accessible and files that cannot be parsed fail loading with a *LoadError according to
+// Strict and the path markers, see WithStrict
+func (opts *Options) readConfigLayers() ([]configLayer, error) {
+	layers := make([]configLayer, 0, len(opts.UserConfigPaths))
+	errs := &loadErrors{strict: opts.Strict}
+	for _, p := range opts.configPaths() {
+		source, format, err := opts.openSource(p.path)
+		if err != nil {
+			return nil, err
+		}
+		if source != nil {
+			layer, err := opts.readSourceLayer(source, format)
+			if err != nil {
+				// sources are given explicitly, so they are required unless marked optional
+				p.required = !p.optional
+				errs.add(p, asFileError(source.String(), err), false)
+				continue
+			}
+			logger.Debugf("merged config source '%s'", source)
+			layers = append(layers, layer)
+			continue
+		}
+		if !file.IsAccessible(p.path) {
+			errs.add(p, &FileError{File: p.path, Err: fmt.Errorf("not accessible")}, true)
+			continue
+		}
+		configFile := opts.findConfigFile(p.path)
+		if configFile == "" {
+			if p.required {
+				errs.add(p, &FileError{File: p.path, Err: fmt.Errorf("no config file '%s' found", opts.ConfigName)}, true)
+			}
+			logger.Debugf("no config file '%s' found in '%s'", opts.ConfigName, p.path)
+		}
+		for _, f := range append([]string{configFile}, opts.dropInFiles(p.path)...) {
+			if f == "" {
+				continue
+			}
+			settings, err := opts.readSettings(f)
+			if err != nil {
+				errs.add(p, asFileError(f, err), false)
+				continue
+			}
+			logger.Debugf("merged config file '%s'", f)
+			layers = append(layers, opts.readIncludeLayers(f, settings, nil, p, errs)...)
+		}
 	}
-	val := ViperGetString(cmd, key)
-	if val != "" {
-		args = append(args, regexp.MustCompile(splitPattern).Split(val, -1)...)
+	layers, err := opts.readProfileLayers(layers, errs)
+	if err != nil {
+		return nil, err
 	}
-	return args
+	return layers, errs.err()
 }
 
-// ViperBindPFlag is a convenience wrapper over viper.BindPFlag for local flags
-func ViperBindPFlag(cmd *cobra.Command, name string) {
-	_ = viper.BindPFlag(PrefixKey(cmd, name), cmd.Flags().Lookup(name))
+// findConfigFile returns path itself when it is a file, otherwise the first ConfigName file with a supported extension
+func (opts *Options) findConfigFile(path string) string {
+	if file.IsFile(path) {
+		return path
+	}
+	for _, ext := range opts.configExts() {
+		configFile := filepath.Join(path, stringutil.ConcatStrings(opts.ConfigName, ".", ext))
+		if file.IsFile(configFile) {
+			return configFile
+		}
+	}
+	return ""
 }
 
-// ViperBindPFlagSet is a convenience wrapper over viper.BindPFlag for local FlagSet
-//
-// if flags is nil, the cmd.Flags() will be used
-func ViperBindPFlagSet(cmd *cobra.Command, flags *pflag.FlagSet) {
-	if flags == nil {
-		flags = cmd.Flags()
+// configExts returns ConfigType if set, viper.SupportedExts otherwise
+func (opts *Options) configExts() []string {
+	if opts.ConfigType != "" {
+		return []string{opts.ConfigType}
 	}
-	flags.VisitAll(func(flag *pflag.Flag) {
-		_ = viper.BindPFlag(PrefixKey(cmd, flag.Name), flag)
-	})
+	return viper.SupportedExts
 }
 
-// ViperBindPersistentPFlag is a convenience wrapper over viper.BindPFlag for persistent flags
-func ViperBindPersistentPFlag(cmd *cobra.Command, name string) {
-	_ = viper.BindPFlag(PrefixKey(cmd, name), cmd.PersistentFlags().Lookup(name))
+// readConfigFile returns the settings of configFile. If configType is empty, it is deduced from the file extension.
+// Failures are returned as a *FileError
+func readConfigFile(configFile, configType string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, &FileError{File: configFile, Err: err}
+	}
+	if configType == "" {
+		configType = strings.TrimPrefix(filepath.Ext(configFile), ".")
+	}
+	v := newViper()
+	v.SetConfigType(configType)
+	if err = v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, newFileError(configFile, content, err)
+	}
+	return v.AllSettings(), nil
 }
 
-// ViperGetString is a convenience wrapper that returns the value associated with the key as a string.
-func ViperGetString(cmd *cobra.Command, key string) string {
-	return viper.GetViper().GetString(PrefixKey(cmd, key))
+// replaceConfig swaps the whole config file layer of v with cfg, so keys removed from files are dropped
+func replaceConfig(v *viper.Viper, cfg map[string]interface{}) error {
+	v.SetConfigType("json")
+	if err := v.ReadConfig(strings.NewReader("{}")); err != nil {
+		return err
+	}
+	return v.MergeConfigMap(cfg)
 }
 
-// ViperGetStringSlice is a convenience wrapper that returns the value associated with the key as a slice of strings.
-func ViperGetStringSlice(cmd *cobra.Command, key string) []string {
-	return viper.GetViper().GetStringSlice(PrefixKey(cmd, key))
-}
+// envKeyReplacer maps config keys to environment variable names, the same way viper does
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
 
-// ViperGetStringMap is a convenience wrapper that returns the value associated with the key as a map of interfaces.
-func ViperGetStringMap(cmd *cobra.Command, key string) map[string]interface{} {
-	return viper.GetViper().GetStringMap(PrefixKey(cmd, key))
+// WithKeySeparator joins the command names and the key with sep instead of ".", see PrefixKey.
+// Another separator, like "-", makes flat keys: `deploy-name` instead of `deploy.name`
+func WithKeySeparator(sep string) Option {
+	return func(o *Options) {
+		o.KeySeparator = sep
+	}
 }
 
-// ViperGetStringMapString is a convenience wrapper that returns the value associated with the key as a map of strings.
-func ViperGetStringMapString(cmd *cobra.Command, key string) map[string]string {
-	return viper.GetViper().GetStringMapString(PrefixKey(cmd, key))
+// WithUnprefixedRootFlags keeps the keys of the root command persistent flags unprefixed when looked up from
+// subcommands, so `--log-level` inherited by `app deploy` is still `log-level`, not `deploy.log-level`
+func WithUnprefixedRootFlags(unprefixed bool) Option {
+	return func(o *Options) {
+		o.UnprefixedRootFlags = unprefixed
+	}
 }
 
-// ViperGetStringMapStringSlice is a convenience wrapper that returns the value associated with the key as a map to a slice of strings.
-func ViperGetStringMapStringSlice(cmd *cobra.Command, key string) map[string][]string {
-	return viper.GetViper().GetStringMapStringSlice(PrefixKey(cmd, key))
+// BuildEnvKey returns a fully constructed environment variable name, matching what viper looks up for the key:
+// `<PREFIX>_DEPLOY_REQUEST_TIMEOUT`. options customize the key like for PrefixKey
+func BuildEnvKey(cmd *cobra.Command, envPrefix string, keyName string, options ...Option) string {
+	return newKeyOptions(options).buildEnvKey(cmd, envPrefix, keyName)
 }
 
-// ViperGetInt is a convenience wrapper that returns the value associated with the key as an integer.
-func ViperGetInt(cmd *cobra.Command, key string) int {
-	return viper.GetViper().GetInt(PrefixKey(cmd, key))
+// PrefixKey prepends the names of cmd and its parents, up to the root command excluded, to the key name.
+// Only WithKeySeparator and WithUnprefixedRootFlags options are relevant
+func PrefixKey(cmd *cobra.Command, keyName string, options ...Option) string {
+	return newKeyOptions(options).prefixKey(cmd, keyName)
 }
 
-// ViperGetFloat64 is a convenience wrapper that returns the value associated with the key as a float64.
-func ViperGetFloat64(cmd *cobra.Command, key string) float64 {
-	return viper.GetViper().GetFloat64(PrefixKey(cmd, key))
+func newKeyOptions(options []Option) *Options {
+	opts := &Options{}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
 }
 
-// ViperGetTime is a convenience wrapper that returns the value associated with the key as time.
-func ViperGetTime(cmd *cobra.Command, key string) time.Time {
-	return viper.GetViper().GetTime(PrefixKey(cmd, key))
+// envKeyReplacer returns the replacer of the config keys built with the KeySeparator
+func (opts *Options) envKeyReplacer() *strings.Replacer {
+	if sep := opts.keySeparator(); sep != "." {
+		return strings.NewReplacer(".", "_", "-", "_", sep, "_")
+	}
+	return envKeyReplacer
 }
 
-// ViperGetDuration is a convenience wrapper that returns the value associated with the key as a duration.
-func ViperGetDuration(cmd *cobra.Command, key string) time.Duration {
-	return viper.GetViper().GetDuration(PrefixKey(cmd, key))
+func (opts *Options) buildEnvKey(cmd *cobra.Command, envPrefix string, keyName string) string {
+	if len(envPrefix) == 0 {
+		envPrefix = defaults.ViperEnvPrefix
+	}
+	key := opts.prefixKey(cmd, keyName)
+	if key == "" {
+		return strings.ToUpper(envPrefix)
+	}
+	return strings.ToUpper(opts.envKeyReplacer().Replace(stringutil.ConcatStrings(envPrefix, "_", key)))
 }
 
-// ViperGetBool is a convenience wrapper that returns the value associated with the key as a boolean.
-func ViperGetBool(cmd *cobra.Command, key string) bool {
-	return viper.GetViper().GetBool(PrefixKey(cmd, key))
+// keySeparator returns the separator joining the command names and the key, see WithKeySeparator
+func (opts *Options) keySeparator() string {
+	if opts == nil || opts.KeySeparator == "" {
+		return "."
+	}
+	return opts.KeySeparator
 }
 
-// ViperGetSizeInBytes is a convenience wrapper that returns the size of the value associated with the given key
-func ViperGetSizeInBytes(cmd *cobra.Command, key string) uint {
-	return viper.GetViper().GetSizeInBytes(PrefixKey(cmd, key))
+func (opts *Options) prefixKey(cmd *cobra.Command, keyName string) string {
+	if opts != nil && opts.UnprefixedRootFlags && keyName != "" && cmd != nil &&
+		cmd.Root().PersistentFlags().Lookup(keyName) != nil {
+		return keyName
+	}
+	sep := opts.keySeparator()
+	parentKey := ""
+	for cmd != nil && cmd != cmd.Root() {
+		parentKey = stringutil.ConcatStrings(cmd.Name(), sep, parentKey)
+		cmd = cmd.Parent()
+	}
+	if keyName == "" {
+		return strings.TrimSuffix(parentKey, sep)
+	}
+	return parentKey + keyName
 }
 
-// ViperIsSet is a convenience wrapper returning true if a key is set. Case insensitive for keys.
-func ViperIsSet(cmd *cobra.Command, key string) bool {
-	return viper.GetViper().IsSet(PrefixKey(cmd, key))
+// prefixKey is PrefixKey with the key options of c
+func (c *Config) prefixKey(cmd *cobra.Command, keyName string) string {
+	return c.opts.prefixKey(cmd, keyName)
 }
 
-// ViperSet is a convenience wrapper setting an override value for specified key
-func ViperSet(cmd *cobra.Command, key, value string) {
-	viper.GetViper().Set(PrefixKey(cmd, key), value)
+// envKey is BuildEnvKey with the env prefix and key options of c
+func (c *Config) envKey(cmd *cobra.Command, keyName string) string {
+	return c.opts.buildEnvKey(cmd, c.envPrefix(), keyName)
 }
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+func newKeyTestCommands() (root, deploy, status *cobra.Command) {
+	root = &cobra.Command{Use: "app"}
+	root.PersistentFlags().String("log-level", "info", "")
+	deploy = &cobra.Command{Use: "deploy [flags] <name>", Aliases: []string{"d"}}
+	status = &cobra.Command{Use: "status <id>"}
+	root.AddCommand(deploy)
+	deploy.AddCommand(status)
+	return root, deploy, status
+}
+
+func TestPrefixKey(t *testing.T) {
+	root, deploy, status := newKeyTestCommands()
+	tests := []struct {
+		name     string
+		cmd      *cobra.Command
+		key      string
+		options  []Option
+		expected string
+		envKey   string
+	}{
+		{"no command", nil, "timeout", nil, "timeout", "TEST_TIMEOUT"},
+		{"root", root, "timeout", nil, "timeout", "TEST_TIMEOUT"},
+		{"subcommand", deploy, "request-timeout", nil, "deploy.request-timeout", "TEST_DEPLOY_REQUEST_TIMEOUT"},
+		{"nested", status, "server.port", nil, "deploy.status.server.port", "TEST_DEPLOY_STATUS_SERVER_PORT"},
+		{"empty key", status, "", nil, "deploy.status", "TEST_DEPLOY_STATUS"},
+		{"separator", status, "port", []Option{WithKeySeparator(":")}, "deploy:status:port", "TEST_DEPLOY_STATUS_PORT"},
+		{"separator empty key", status, "", []Option{WithKeySeparator("-")}, "deploy-status", "TEST_DEPLOY_STATUS"},
+		{"root flag prefixed", deploy, "log-level", nil, "deploy.log-level", "TEST_DEPLOY_LOG_LEVEL"},
+		{"root flag", status, "log-level", []Option{WithUnprefixedRootFlags(true)}, "log-level", "TEST_LOG_LEVEL"},
+		{"other flag", status, "port", []Option{WithUnprefixedRootFlags(true)},
+			"deploy.status.port", "TEST_DEPLOY_STATUS_PORT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if key := PrefixKey(tt.cmd, tt.key, tt.options...); key != tt.expected {
+				t.Errorf("Expected key '%s', got '%s'", tt.expected, key)
+			}
+			if envKey := BuildEnvKey(tt.cmd, "test", tt.key, tt.options...); envKey != tt.envKey {
+				t.Errorf("Expected env key '%s', got '%s'", tt.envKey, envKey)
+			}
+		})
+	}
+}
+
+func TestBuildEnvKey(t *testing.T) {
+	root, _, status := newKeyTestCommands()
+	tests := []struct {
+		name      string
+		cmd       *cobra.Command
+		envPrefix string
+		key       string
+		expected  string
+	}{
+		{"no command", nil, "test", "timeout", "TEST_TIMEOUT"},
+		{"root", root, "test", "timeout", "TEST_TIMEOUT"},
+		{"default prefix", nil, "", "timeout", strings.ToUpper(defaults.ViperEnvPrefix) + "_TIMEOUT"},
+		{"subcommand", status, "test", "request-timeout", "TEST_DEPLOY_STATUS_REQUEST_TIMEOUT"},
+		{"empty key", status, "test", "", "TEST_DEPLOY_STATUS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if envKey := BuildEnvKey(tt.cmd, tt.envPrefix, tt.key); envKey != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, envKey)
+			}
+		})
+	}
+}
+
+func TestKeyForEnv(t *testing.T) {
+	_, deploy, _ := newKeyTestCommands()
+	c := newSourceTestConfig(t, nil)
+	if err := c.RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterAlias(deploy, "timeout", "request-timeout"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"TEST_DEPLOY_REQUEST_TIMEOUT", "deploy.request-timeout"},
+		{"test_deploy_request_timeout", "deploy.request-timeout"},
+		{"TEST_DEPLOY_TIMEOUT", "deploy.request-timeout"},
+		{"TEST_LOG_LEVEL", "log-level"},
+		{"TEST_UNKNOWN", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := c.KeyForEnv(tt.name)
+			if key != tt.expected || ok != (tt.expected != "") {
+				t.Errorf("Expected '%s', got '%s' (%v)", tt.expected, key, ok)
+			}
+		})
+	}
+}
+
+func TestConfigIsolation(t *testing.T) {
+	t.Setenv("ONE_DEPLOY_REQUEST_TIMEOUT", "1m")
+	t.Setenv("TWO_DEPLOY_REQUEST_TIMEOUT", "2m")
+	configs := make([]*Config, 0, 2)
+	for _, prefix := range []string{"ONE", "TWO"} {
+		opts, err := NewOptions(WithEnvPrefix(prefix), WithUserConfigPaths(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewConfig(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, deploy, _ := newKeyTestCommands()
+		if err = c.RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if err = c.InitConfig(); err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, c)
+	}
+
+	one, two := configs[0], configs[1]
+	if timeout := one.Viper().GetString("deploy.request-timeout"); timeout != "1m" {
+		t.Errorf("Expected the first config to read its env variable, got '%s'", timeout)
+	}
+	if timeout := two.Viper().GetString("deploy.request-timeout"); timeout != "2m" {
+		t.Errorf("Expected the second config to read its env variable, got '%s'", timeout)
+	}
+	one.Set(nil, "name", "one")
+	if two.Viper().IsSet("name") || Default().Viper().IsSet("name") {
+		t.Error("Expected a value set in one config not to leak into the others")
+	}
+}
+
+func TestDefaultConfigOptions(t *testing.T) {
+	previous := Default()
+	SetDefault(&Config{viper: newViper()})
+	defer SetDefault(previous)
+
+	opts, err := NewOptions(WithEnvPrefix("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Default().Options() != nil {
+		t.Fatal("Expected NewOptions not to change the default config")
+	}
+	Default().Use(opts)
+	// flags registered before InitConfig name the env variable of the Options
+	_, deploy, _ := newKeyTestCommands()
+	if err = RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	usage := deploy.Flags().Lookup("request-timeout").Usage
+	if !strings.Contains(usage, "FIRST_DEPLOY_REQUEST_TIMEOUT") {
+		t.Errorf("Expected the usage to name FIRST_DEPLOY_REQUEST_TIMEOUT, got '%s'", usage)
+	}
+}
+
+// jsonEventsWriter records the log events, failing like the logfmt format on writes that are not one JSON event
+type jsonEventsWriter struct {
+	mu       sync.Mutex
+	messages []string
+	invalid  []string
+}
+
+func (w *jsonEventsWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var event map[string]interface{}
+	if err := json.Unmarshal(p, &event); err != nil {
+		w.invalid = append(w.invalid, string(p))
+		return 0, err
+	}
+	message, _ := event["message"].(string)
+	w.messages = append(w.messages, message)
+	return len(p), nil
+}
+
+func TestTraceDump(t *testing.T) {
+	events := &jsonEventsWriter{}
+	log.RegisterLogFormat("events", func(io.Writer, bool) io.Writer { return events })
+	defer func() {
+		_ = log.SetLogFormat(log.FormatConsole)
+		_ = log.SetLogLevel(log.InfoLevel.String())
+	}()
+	t.Setenv("TEST_LOG_FORMAT", "events")
+	t.Setenv("TEST_LOG_LEVEL", "trace")
+
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "server:\n  port: 8080\n")
+	c := newSourceTestConfig(t, []string{path})
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(events.invalid) > 0 {
+		t.Errorf("Expected only JSON events, got %q", events.invalid)
+	}
+	for _, message := range events.messages {
+		if strings.HasPrefix(message, "viper configuration dump") {
+			return
+		}
+	}
+	t.Errorf("Expected the configuration dump in the events, got %q", events.messages)
+}
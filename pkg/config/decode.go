@@ -0,0 +1,279 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cobra"
+	"github.com/thedataflows/go-commons/pkg/reflectutil"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// Struct tags understood by Decode
+const (
+	TagKey      = "config"
+	TagDefault  = "default"
+	TagRequired = "required"
+	TagMin      = "min"
+	TagMax      = "max"
+	TagOneOf    = "oneof"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// FieldError reports a config key that failed decoding or validation
+type FieldError struct {
+	Key    string
+	Flag   string
+	EnvVar string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s (flag --%s, env %s): %s", e.Key, e.Flag, e.EnvVar, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError aggregates all the FieldErrors found while decoding a struct
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var strBuilder strings.Builder
+	strBuilder.WriteString("invalid configuration:")
+	for _, fe := range e.Errors {
+		strBuilder.WriteString("\n  ")
+		strBuilder.WriteString(fe.Error())
+	}
+	return strBuilder.String()
+}
+
+// structKeyField is a leaf field of a config struct along with its key relative to the command prefix
+type structKeyField struct {
+	key   string
+	field reflect.StructField
+	value reflect.Value
+}
+
+// Decode returns a T filled from the config subtree of cmd, using the Default() Config. See Config.Decode
+func Decode[T any](cmd *cobra.Command) (T, error) {
+	return DecodeWith[T](Default(), cmd)
+}
+
+// DecodeWith returns a T filled from the config subtree of cmd, using c. See Config.Decode
+func DecodeWith[T any](c *Config, cmd *cobra.Command) (T, error) {
+	var out T
+	err := c.Decode(cmd, &out)
+	return out, err
+}
+
+// Decode fills the struct pointed to by out with the values of the keys prefixed by cmd (see PrefixKey).
+//
+// Fields are matched by the `config` tag or else by their kebab-cased name, nested structs become dotted keys
+// and `config:"-"` skips a field. The `default` tag is used when the key is not set at all, then `required`,
+// `min`, `max` (value for numbers, length for strings, slices and maps) and `oneof` (space separated) are checked.
+// All failures are returned at once as a *ValidationError.
+func (c *Config) Decode(cmd *cobra.Command, out any) error {
+	fields, err := structKeyFields(out)
+	if err != nil {
+		return err
+	}
+	c.registerSchemaFields(cmd, fields)
+
+	validationErr := &ValidationError{}
+	for _, f := range fields {
+		if err := c.decodeField(cmd, f); err != nil {
+			validationErr.Errors = append(validationErr.Errors, &FieldError{
+				Key:    c.prefixKey(cmd, f.key),
+				Flag:   f.key,
+				EnvVar: c.envKey(cmd, f.key),
+				Err:    err,
+			})
+		}
+	}
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+func (c *Config) decodeField(cmd *cobra.Command, f structKeyField) error {
+	key := c.prefixKey(cmd, f.key)
+	c.mu.RLock()
+	isSet := c.viper.IsSet(key)
+	raw := c.viper.Get(key)
+	c.mu.RUnlock()
+	defaultValue, hasDefault := f.field.Tag.Lookup(TagDefault)
+	if !isSet && !hasDefault && isTrue(f.field.Tag.Get(TagRequired)) {
+		return fmt.Errorf("is required")
+	}
+
+	// flag defaults and viper defaults are not "set", but still take precedence over the default tag
+	if !isSet && raw == nil {
+		if !hasDefault {
+			return nil
+		}
+		raw = defaultValue
+	}
+	if err := decodeValue(raw, f.value); err != nil {
+		return fmt.Errorf("invalid value '%v': %w", raw, err)
+	}
+	return validateField(f.field, f.value)
+}
+
+// envPrefix returns the configured env prefix or an empty string if not initialized yet
+func (c *Config) envPrefix() string {
+	if c.opts == nil {
+		return ""
+	}
+	return c.opts.EnvPrefix
+}
+
+// structKeyFields returns the leaf fields of the struct pointed to by out along with their dotted keys
+func structKeyFields(out any) ([]structKeyField, error) {
+	fields := make([]structKeyField, 0)
+	err := reflectutil.WalkStructFields(
+		out,
+		isNestedConfig,
+		func(parents []reflect.StructField, field reflect.StructField, value reflect.Value) error {
+			key, ok := fieldKey(parents, field)
+			if ok {
+				fields = append(fields, structKeyField{key: key, field: field, value: value})
+			}
+			return nil
+		},
+	)
+	return fields, err
+}
+
+// isNestedConfig returns true for struct fields holding nested keys rather than a single value
+func isNestedConfig(field reflect.StructField) bool {
+	t := field.Type
+	return field.Tag.Get(TagKey) != "-" &&
+		t != reflect.TypeOf(time.Time{}) &&
+		!t.Implements(textUnmarshalerType) &&
+		!reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// fieldKey returns the dotted key of field. Embedded structs do not add a key segment
+func fieldKey(parents []reflect.StructField, field reflect.StructField) (string, bool) {
+	segments := make([]string, 0, len(parents)+1)
+	for _, f := range append(parents[:len(parents):len(parents)], field) {
+		name := f.Tag.Get(TagKey)
+		if name == "-" {
+			return "", false
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		if name == "" {
+			name = stringutil.ToKebabCase(f.Name)
+		}
+		segments = append(segments, name)
+	}
+	return strings.Join(segments, "."), true
+}
+
+// decodeValue converts raw into value, weakly typed as config values often come from strings
+func decodeValue(raw any, value reflect.Value) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			// before splitting strings, as some TextUnmarshalers like net.IP are slices
+			mapstructure.TextUnmarshallerHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		ZeroFields:       true,
+		Result:           value.Addr().Interface(),
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(raw)
+}
+
+// validateField checks value against min, max and oneof tags
+func validateField(field reflect.StructField, value reflect.Value) error {
+	if bound, ok := field.Tag.Lookup(TagMin); ok {
+		n, b, err := measure(value, bound)
+		if err != nil {
+			return err
+		}
+		if n < b {
+			return fmt.Errorf("must be at least %s", bound)
+		}
+	}
+	if bound, ok := field.Tag.Lookup(TagMax); ok {
+		n, b, err := measure(value, bound)
+		if err != nil {
+			return err
+		}
+		if n > b {
+			return fmt.Errorf("must be at most %s", bound)
+		}
+	}
+	if oneOf, ok := field.Tag.Lookup(TagOneOf); ok {
+		allowed := strings.Fields(oneOf)
+		values := []reflect.Value{value}
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+			values = make([]reflect.Value, value.Len())
+			for i := range values {
+				values[i] = value.Index(i)
+			}
+		}
+		for _, v := range values {
+			s := fmt.Sprint(v.Interface())
+			if !containsString(allowed, s) {
+				return fmt.Errorf("'%s' must be one of: %s", s, strings.Join(allowed, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// measure returns what min and max compare: the value of numbers or the length of strings, slices and maps
+func measure(value reflect.Value, bound string) (float64, float64, error) {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		b, err := time.ParseDuration(bound)
+		return float64(value.Int()), float64(b), err
+	}
+	b, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bound '%s': %w", bound, err)
+	}
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), b, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), b, nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), b, nil
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), b, nil
+	}
+	return 0, 0, fmt.Errorf("min and max are not supported for %s", value.Type())
+}
+
+func isTrue(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type testServerConfig struct {
+	Host string `default:"localhost"`
+	Port int    `default:"8080" min:"1" max:"65535"`
+}
+
+type testDeployConfig struct {
+	Name           string        `required:"true"`
+	Strategy       string        `default:"rolling" oneof:"rolling recreate"`
+	RequestTimeout time.Duration `default:"30s" min:"1s"`
+	Tags           []string
+	Server         testServerConfig
+	Ignored        string `config:"-"`
+}
+
+func newTestCommand() *cobra.Command {
+	root := &cobra.Command{Use: "app"}
+	deploy := &cobra.Command{Use: "deploy"}
+	root.AddCommand(deploy)
+	return deploy
+}
+
+func TestDecode(t *testing.T) {
+	c, err := NewConfig(&Options{EnvPrefix: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := newTestCommand()
+	c.Viper().Set("deploy.name", "api")
+	c.Viper().Set("deploy.tags", "a,b")
+	c.Viper().Set("deploy.server.port", "9090")
+	c.Viper().Set("deploy.ignored", "x")
+
+	cfg, err := DecodeWith[testDeployConfig](c, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testDeployConfig{
+		Name:           "api",
+		Strategy:       "rolling",
+		RequestTimeout: 30 * time.Second,
+		Tags:           []string{"a", "b"},
+		Server:         testServerConfig{Host: "localhost", Port: 9090},
+	}
+	if cfg.Name != expected.Name || cfg.Strategy != expected.Strategy || cfg.RequestTimeout != expected.RequestTimeout ||
+		len(cfg.Tags) != 2 || cfg.Server != expected.Server || cfg.Ignored != "" {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+}
+
+func TestDecodeValidation(t *testing.T) {
+	c, err := NewConfig(&Options{EnvPrefix: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := newTestCommand()
+	c.Viper().Set("deploy.strategy", "blue-green")
+	c.Viper().Set("deploy.request-timeout", "10ms")
+	c.Viper().Set("deploy.server.port", 0)
+
+	_, err = DecodeWith[testDeployConfig](c, cmd)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	expected := map[string]string{
+		"deploy.name":            "TEST_DEPLOY_NAME",
+		"deploy.strategy":        "TEST_DEPLOY_STRATEGY",
+		"deploy.request-timeout": "TEST_DEPLOY_REQUEST_TIMEOUT",
+		"deploy.server.port":     "TEST_DEPLOY_SERVER_PORT",
+	}
+	if len(validationErr.Errors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), err)
+	}
+	for _, fe := range validationErr.Errors {
+		if env, ok := expected[fe.Key]; !ok || env != fe.EnvVar {
+			t.Errorf("Unexpected error %v", fe)
+		}
+	}
+}
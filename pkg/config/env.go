@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/subosito/gotenv"
+	"github.com/thedataflows/go-commons/pkg/file"
+)
+
+// Output formats of EnvVars
+const (
+	OutputDotenv   = "dotenv"
+	OutputShell    = "shell"
+	OutputMarkdown = "markdown"
+)
+
+// WithEnvFiles loads, in order, the given .env files into the environment before InitConfig reads it.
+// Variables already set in the environment are kept, as well as the ones set by a previous file.
+// Missing files are skipped
+func WithEnvFiles(envFiles ...string) Option {
+	return func(o *Options) {
+		o.EnvFiles = envFiles
+	}
+}
+
+// loadEnvFiles loads EnvFiles into the environment, see WithEnvFiles
+func (opts *Options) loadEnvFiles() error {
+	for _, f := range opts.EnvFiles {
+		if !file.IsFile(f) {
+			logger.Debugf("no env file '%s'", f)
+			continue
+		}
+		if err := gotenv.Load(f); err != nil {
+			return fmt.Errorf("failed to load env file '%s': %w", f, err)
+		}
+		logger.Debugf("loaded env file '%s'", f)
+	}
+	return nil
+}
+
+// EnvVar documents the environment variable of a config key
+type EnvVar struct {
+	Name    string      `json:"name"`
+	Key     string      `json:"key"`
+	Type    string      `json:"type"`
+	Default string      `json:"default"`
+	Value   interface{} `json:"value"`
+	Usage   string      `json:"usage,omitempty"`
+}
+
+// EnvVars returns the environment variable of every key bound to a flag, and of the Options flags,
+// sorted by name. Secret values are masked
+func (c *Config) EnvVars() []EnvVar {
+	flags := make(map[string]*pflag.Flag)
+	if c.opts != nil && c.opts.Flags != nil {
+		c.opts.Flags.VisitAll(func(flag *pflag.Flag) {
+			if flag.Name != "config" {
+				flags[strings.ToLower(flag.Name)] = flag
+			}
+		})
+	}
+	c.stateMu.RLock()
+	for k, flag := range c.bindings {
+		flags[k] = flag
+	}
+	c.stateMu.RUnlock()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	envVars := make([]EnvVar, 0, len(flags))
+	for key, flag := range flags {
+		name := c.envKey(nil, key)
+		envVar := EnvVar{
+			Name:    name,
+			Key:     key,
+			Type:    flag.Value.Type(),
+			Default: flag.DefValue,
+			Value:   c.viper.Get(key),
+			// RegisterFlags appends the env variable to the usage already
+			Usage: strings.TrimSpace(strings.TrimSuffix(flag.Usage, fmt.Sprintf("[env %s]", name))),
+		}
+		if envVar.Value == nil {
+			// Options flags are not bound, InitConfig reads them directly
+			envVar.Value = flag.Value.String()
+		}
+		if c.IsSecret(key) || c.hasSecretItems(key) {
+			envVar.Value = SecretMask
+		}
+		envVars = append(envVars, envVar)
+	}
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].Name < envVars[j].Name })
+	return envVars
+}
+
+// KeyForEnv returns the config key read from the environment variable name, looked up among the keys known to c
+// (see Keys), the Options flags and the aliases. The env name of a key is lossy, so the first matching key,
+// in sorted order, wins
+func (c *Config) KeyForEnv(name string) (string, bool) {
+	name = strings.ToUpper(name)
+	c.stateMu.RLock()
+	for oldKey, a := range c.aliases {
+		if c.envKey(nil, oldKey) == name {
+			c.stateMu.RUnlock()
+			return a.newKey, true
+		}
+	}
+	c.stateMu.RUnlock()
+
+	keys := c.Keys()
+	if c.opts != nil && c.opts.Flags != nil {
+		c.opts.Flags.VisitAll(func(flag *pflag.Flag) {
+			keys = append(keys, strings.ToLower(flag.Name))
+		})
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if c.envKey(nil, key) == name {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// KeyForEnv returns the config key read from the environment variable name using the Default() Config, see
+// Config.KeyForEnv
+func KeyForEnv(name string) (string, bool) {
+	return Default().KeyForEnv(name)
+}
+
+// WriteEnvVars writes envVars to w in output format: OutputDotenv, OutputShell or OutputMarkdown
+func WriteEnvVars(w io.Writer, envVars []EnvVar, output string) error {
+	switch output {
+	case OutputDotenv:
+		for _, e := range envVars {
+			fmt.Fprintf(w, "# %s\n%s=%s\n", envVarComment(e), e.Name, strconv.Quote(envValue(e.Value)))
+		}
+	case OutputShell:
+		for _, e := range envVars {
+			fmt.Fprintf(w, "# %s\nexport %s=%s\n", envVarComment(e), e.Name, shellQuote(envValue(e.Value)))
+		}
+	case OutputMarkdown:
+		fmt.Fprintln(w, "| Variable | Key | Type | Default | Value | Description |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- |")
+		for _, e := range envVars {
+			fmt.Fprintf(w, "| `%s` | `%s` | %s | %s | %s | %s |\n",
+				e.Name, e.Key, e.Type, markdownCell(e.Default), markdownCell(envValue(e.Value)), markdownCell(e.Usage))
+		}
+	default:
+		return fmt.Errorf("invalid output format '%s'. Provide one of: %s, %s, %s",
+			output, OutputDotenv, OutputShell, OutputMarkdown)
+	}
+	return nil
+}
+
+// NewEnvCommand returns a hidden `env` command printing the environment variables of the config keys.
+// If c is nil, Default() is used
+func NewEnvCommand(c *Config) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:    "env",
+		Short:  "Print the environment variables of the config keys with their current values",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if c == nil {
+				c = Default()
+			}
+			return WriteEnvVars(cmd.OutOrStdout(), c.EnvVars(), output)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", OutputDotenv,
+		fmt.Sprintf("Output format, one of: '%s, %s, %s'", OutputDotenv, OutputShell, OutputMarkdown))
+	_ = cmd.RegisterFlagCompletionFunc("output", CompleteValues(func() []string {
+		return []string{OutputDotenv, OutputShell, OutputMarkdown}
+	}))
+	return cmd
+}
+
+func envVarComment(e EnvVar) string {
+	comment := fmt.Sprintf("%s (%s, default '%s')", e.Key, e.Type, e.Default)
+	if e.Usage != "" {
+		comment = fmt.Sprintf("%s: %s", comment, e.Usage)
+	}
+	return strings.ReplaceAll(comment, "\n", " ")
+}
+
+// envValue formats value the way it is parsed back from an environment variable
+func envValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// shellQuote single quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		env      map[string]string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name:     "single file",
+			files:    map[string]string{"a.env": "TEST_ENV_ONE=1\nexport TEST_ENV_TWO='two words'\n"},
+			expected: map[string]string{"TEST_ENV_ONE": "1", "TEST_ENV_TWO": "two words"},
+		},
+		{
+			name:     "first file wins",
+			files:    map[string]string{"a.env": "TEST_ENV_ONE=a\n", "b.env": "TEST_ENV_ONE=b\nTEST_ENV_TWO=b\n"},
+			expected: map[string]string{"TEST_ENV_ONE": "a", "TEST_ENV_TWO": "b"},
+		},
+		{
+			name:     "environment wins",
+			files:    map[string]string{"a.env": "TEST_ENV_ONE=file\n"},
+			env:      map[string]string{"TEST_ENV_ONE": "env"},
+			expected: map[string]string{"TEST_ENV_ONE": "env"},
+		},
+		{
+			name:     "missing file",
+			expected: map[string]string{"TEST_ENV_ONE": ""},
+		},
+		{
+			name:  "invalid file",
+			files: map[string]string{"a.env": "TEST_ENV_ONE='unterminated\n"},
+			err:   "failed to load env file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// t.Setenv restores the variables the env files set
+			for _, name := range []string{"TEST_ENV_ONE", "TEST_ENV_TWO"} {
+				t.Setenv(name, "")
+				_ = os.Unsetenv(name)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			dir := t.TempDir()
+			envFiles := []string{filepath.Join(dir, "a.env"), filepath.Join(dir, "b.env")}
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			err := newSourceTestConfig(t, nil, WithEnvFiles(envFiles...)).InitConfig()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, expected := range tt.expected {
+				if value := os.Getenv(name); value != expected {
+					t.Errorf("Expected %s '%s', got '%s'", name, expected, value)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	t.Setenv("TEST_DEPLOY_REQUEST_TIMEOUT", "5s")
+	t.Setenv("TEST_PASSWORD", "s3cret")
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "password: ${env:TEST_PASSWORD}\n")
+	c := newSourceTestConfig(t, []string{path})
+	_, deploy, _ := newKeyTestCommands()
+	if err := c.RegisterFlags(deploy, &testAliasConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	envVars := make(map[string]EnvVar)
+	for _, e := range c.EnvVars() {
+		envVars[e.Name] = e
+	}
+	tests := []struct {
+		name     string
+		expected EnvVar
+	}{
+		{"TEST_DEPLOY_REQUEST_TIMEOUT", EnvVar{Key: "deploy.request-timeout", Type: "string", Default: "1s", Value: "5s"}},
+		{"TEST_LOG_LEVEL", EnvVar{Key: "log-level", Type: "string", Default: "warn", Value: "warn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := envVars[tt.name]
+			if !ok {
+				t.Fatalf("Expected %s in %v", tt.name, envVars)
+			}
+			if e.Key != tt.expected.Key || e.Type != tt.expected.Type || e.Default != tt.expected.Default ||
+				e.Value != tt.expected.Value || strings.Contains(e.Usage, "[env ") {
+				t.Errorf("Expected %+v, got %+v", tt.expected, e)
+			}
+		})
+	}
+	if _, ok := envVars["TEST_CONFIG"]; ok {
+		t.Error("Expected no env variable for the config flag")
+	}
+}
+
+func TestWriteEnvVars(t *testing.T) {
+	envVars := []EnvVar{
+		{Name: "TEST_HOSTS", Key: "hosts", Type: "stringSlice", Default: "[]", Value: []interface{}{"a", "b"}},
+		{Name: "TEST_NAME", Key: "name", Type: "string", Value: "it's", Usage: "The name | alias"},
+	}
+	tests := []struct {
+		output   string
+		expected string
+		err      string
+	}{
+		{
+			output: OutputDotenv,
+			expected: `# hosts (stringSlice, default '[]')
+TEST_HOSTS="a,b"
+# name (string, default ''): The name | alias
+TEST_NAME="it's"
+`,
+		},
+		{
+			output: OutputShell,
+			expected: `# hosts (stringSlice, default '[]')
+export TEST_HOSTS='a,b'
+# name (string, default ''): The name | alias
+export TEST_NAME='it'\''s'
+`,
+		},
+		{
+			output: OutputMarkdown,
+			expected: "| Variable | Key | Type | Default | Value | Description |\n" +
+				"| --- | --- | --- | --- | --- | --- |\n" +
+				"| `TEST_HOSTS` | `hosts` | stringSlice | [] | a,b |  |\n" +
+				"| `TEST_NAME` | `name` | string |  | it's | The name \\| alias |\n",
+		},
+		{output: "xml", err: "invalid output format 'xml'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			var out bytes.Buffer
+			err := WriteEnvVars(&out, envVars, tt.output)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, out.String())
+			}
+		})
+	}
+}
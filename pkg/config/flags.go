@@ -0,0 +1,216 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Struct tags understood by RegisterFlags, in addition to the ones used by Decode
+const (
+	TagUsage = "usage"
+	TagShort = "short"
+)
+
+// RegisterFlags registers local flags on cmd for every field of the struct pointed to by aStruct,
+// using the Default() Config
+func RegisterFlags(cmd *cobra.Command, aStruct any) error {
+	return Default().RegisterFlags(cmd, aStruct)
+}
+
+// RegisterPersistentFlags registers persistent flags on cmd for every field of the struct pointed to by aStruct,
+// using the Default() Config
+func RegisterPersistentFlags(cmd *cobra.Command, aStruct any) error {
+	return Default().RegisterPersistentFlags(cmd, aStruct)
+}
+
+// RegisterFlags registers local flags on cmd for every field of the struct pointed to by aStruct
+// and binds them under PrefixKey.
+//
+// Flag names are the same keys Decode uses, so nested structs become dotted flags like --server.port.
+// The flag default is the `default` tag or else the current field value, the usage is taken from the `usage` tag
+// and completed with the environment variable name built with the env prefix from Options,
+// and the shorthand from the `short` tag. Flags write into the struct fields directly.
+// The values of the `oneof` tag are offered as shell completions.
+func (c *Config) RegisterFlags(cmd *cobra.Command, aStruct any) error {
+	return c.registerFlags(cmd, cmd.Flags(), aStruct)
+}
+
+// RegisterPersistentFlags is like RegisterFlags, but for persistent flags
+func (c *Config) RegisterPersistentFlags(cmd *cobra.Command, aStruct any) error {
+	return c.registerFlags(cmd, cmd.PersistentFlags(), aStruct)
+}
+
+func (c *Config) registerFlags(cmd *cobra.Command, flags *pflag.FlagSet, aStruct any) error {
+	fields, err := structKeyFields(aStruct)
+	if err != nil {
+		return err
+	}
+	c.registerSchemaFields(cmd, fields)
+	for _, f := range fields {
+		if defaultValue, ok := f.field.Tag.Lookup(TagDefault); ok {
+			if err := decodeValue(defaultValue, f.value); err != nil {
+				return fmt.Errorf("invalid default '%s' for '%s': %w", defaultValue, f.key, err)
+			}
+		}
+		usage := strings.TrimSpace(
+			fmt.Sprintf("%s [env %s]", f.field.Tag.Get(TagUsage), c.envKey(cmd, f.key)),
+		)
+		if err := addFlag(flags, f.value, f.key, f.field.Tag.Get(TagShort), usage); err != nil {
+			return fmt.Errorf("cannot register flag '%s': %w", f.key, err)
+		}
+		if err := c.bindPFlag(c.prefixKey(cmd, f.key), flags.Lookup(f.key)); err != nil {
+			return err
+		}
+		if oneOf, ok := f.field.Tag.Lookup(TagOneOf); ok {
+			values := strings.Fields(oneOf)
+			if err := cmd.RegisterFlagCompletionFunc(f.key, CompleteValues(func() []string { return values })); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addFlag adds a typed flag backed by value, with value's current content as default
+//
+//nolint:cyclop // a flat type switch is the most readable form
+func addFlag(flags *pflag.FlagSet, value reflect.Value, name, short, usage string) error {
+	switch p := value.Addr().Interface().(type) {
+	case *string:
+		flags.StringVarP(p, name, short, *p, usage)
+	case *bool:
+		flags.BoolVarP(p, name, short, *p, usage)
+	case *int:
+		flags.IntVarP(p, name, short, *p, usage)
+	case *int8:
+		flags.Int8VarP(p, name, short, *p, usage)
+	case *int16:
+		flags.Int16VarP(p, name, short, *p, usage)
+	case *int32:
+		flags.Int32VarP(p, name, short, *p, usage)
+	case *int64:
+		flags.Int64VarP(p, name, short, *p, usage)
+	case *uint:
+		flags.UintVarP(p, name, short, *p, usage)
+	case *uint8:
+		flags.Uint8VarP(p, name, short, *p, usage)
+	case *uint16:
+		flags.Uint16VarP(p, name, short, *p, usage)
+	case *uint32:
+		flags.Uint32VarP(p, name, short, *p, usage)
+	case *uint64:
+		flags.Uint64VarP(p, name, short, *p, usage)
+	case *float32:
+		flags.Float32VarP(p, name, short, *p, usage)
+	case *float64:
+		flags.Float64VarP(p, name, short, *p, usage)
+	case *time.Duration:
+		flags.DurationVarP(p, name, short, *p, usage)
+	case *[]string:
+		flags.StringSliceVarP(p, name, short, *p, usage)
+	case *[]int:
+		flags.IntSliceVarP(p, name, short, *p, usage)
+	case *[]int64:
+		flags.Int64SliceVarP(p, name, short, *p, usage)
+	case *[]uint:
+		flags.UintSliceVarP(p, name, short, *p, usage)
+	case *[]float64:
+		flags.Float64SliceVarP(p, name, short, *p, usage)
+	case *[]bool:
+		flags.BoolSliceVarP(p, name, short, *p, usage)
+	case *[]time.Duration:
+		flags.DurationSliceVarP(p, name, short, *p, usage)
+	case *map[string]string:
+		flags.StringToStringVarP(p, name, short, *p, usage)
+	case *map[string]int:
+		flags.StringToIntVarP(p, name, short, *p, usage)
+	case *net.IP:
+		flags.IPVarP(p, name, short, *p, usage)
+	case encoding.TextUnmarshaler:
+		flags.VarP(&textValue{value: value, unmarshaler: p}, name, short, usage)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// textValue is a pflag.Value for types implementing encoding.TextUnmarshaler
+type textValue struct {
+	value       reflect.Value
+	unmarshaler encoding.TextUnmarshaler
+}
+
+func (t *textValue) String() string {
+	if m, ok := t.unmarshaler.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(t.value.Interface())
+}
+
+func (t *textValue) Set(s string) error {
+	return t.unmarshaler.UnmarshalText([]byte(s))
+}
+
+func (t *textValue) Type() string {
+	return t.value.Type().Name()
+}
+
+// sizeValue is a pflag.Value of a size in bytes, written like `100MB` with the suffixes of viper.GetSizeInBytes
+type sizeValue struct {
+	size *int64
+}
+
+// sizeUnits are the size suffixes, largest first
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"gb", 1 << 30},
+	{"mb", 1 << 20},
+	{"kb", 1 << 10},
+	{"b", 1},
+}
+
+func (s *sizeValue) String() string {
+	if s.size == nil || *s.size == 0 {
+		return "0"
+	}
+	for _, u := range sizeUnits {
+		if *s.size%u.bytes == 0 {
+			return strconv.FormatInt(*s.size/u.bytes, 10) + strings.ToUpper(u.suffix)
+		}
+	}
+	return strconv.FormatInt(*s.size, 10)
+}
+
+func (s *sizeValue) Set(value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size '%s', expected a number of bytes optionally followed by KB, MB or GB", value)
+	}
+	*s.size = n * unit
+	return nil
+}
+
+func (s *sizeValue) Type() string {
+	return "size"
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// testMode is a config value parsed from text
+type testMode struct {
+	name string
+}
+
+func (m *testMode) UnmarshalText(text []byte) error {
+	m.name = strings.ToUpper(string(text))
+	return nil
+}
+
+func (m testMode) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(m.name)), nil
+}
+
+type testFlagsConfig struct {
+	String    string `default:"a" short:"s" usage:"A string"`
+	Bool      bool
+	Int       int
+	Int8      int8
+	Int16     int16
+	Int32     int32
+	Int64     int64
+	Uint      uint
+	Uint8     uint8
+	Uint16    uint16
+	Uint32    uint32
+	Uint64    uint64
+	Float32   float32
+	Float64   float64
+	Duration  time.Duration `default:"1s"`
+	Strings   []string
+	Ints      []int
+	Int64s    []int64
+	Uints     []uint
+	Float64s  []float64
+	Bools     []bool
+	Durations []time.Duration
+	Labels    map[string]string
+	Limits    map[string]int
+	IP        net.IP
+	Mode      testMode `default:"fast"`
+	Server    struct {
+		Port int `default:"8080"`
+	}
+	Ignored string `config:"-"`
+}
+
+func TestRegisterFlags(t *testing.T) {
+	_, deploy, _ := newKeyTestCommands()
+	c := newSourceTestConfig(t, nil)
+	cfg := &testFlagsConfig{Int: 7}
+	if err := c.RegisterFlags(deploy, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag     string
+		arg      string
+		defValue string
+		expected any
+		field    func() any
+	}{
+		{"string", "-s=b", "a", "b", func() any { return cfg.String }},
+		{"bool", "--bool", "false", true, func() any { return cfg.Bool }},
+		{"int", "--int=-1", "7", -1, func() any { return cfg.Int }},
+		{"int8", "--int8=-8", "0", int8(-8), func() any { return cfg.Int8 }},
+		{"int16", "--int16=16", "0", int16(16), func() any { return cfg.Int16 }},
+		{"int32", "--int32=32", "0", int32(32), func() any { return cfg.Int32 }},
+		{"int64", "--int64=64", "0", int64(64), func() any { return cfg.Int64 }},
+		{"uint", "--uint=1", "0", uint(1), func() any { return cfg.Uint }},
+		{"uint8", "--uint8=8", "0", uint8(8), func() any { return cfg.Uint8 }},
+		{"uint16", "--uint16=16", "0", uint16(16), func() any { return cfg.Uint16 }},
+		{"uint32", "--uint32=32", "0", uint32(32), func() any { return cfg.Uint32 }},
+		{"uint64", "--uint64=64", "0", uint64(64), func() any { return cfg.Uint64 }},
+		{"float32", "--float32=0.5", "0", float32(0.5), func() any { return cfg.Float32 }},
+		{"float64", "--float64=1.5", "0", 1.5, func() any { return cfg.Float64 }},
+		{"duration", "--duration=1m", "1s", time.Minute, func() any { return cfg.Duration }},
+		{"strings", "--strings=a,b", "[]", []string{"a", "b"}, func() any { return cfg.Strings }},
+		{"ints", "--ints=1,2", "[]", []int{1, 2}, func() any { return cfg.Ints }},
+		{"int64s", "--int64s=3", "[]", []int64{3}, func() any { return cfg.Int64s }},
+		{"uints", "--uints=4", "[]", []uint{4}, func() any { return cfg.Uints }},
+		{"float64s", "--float64s=0.5", "[]", []float64{0.5}, func() any { return cfg.Float64s }},
+		{"bools", "--bools=true,false", "[]", []bool{true, false}, func() any { return cfg.Bools }},
+		{"durations", "--durations=1s", "[]", []time.Duration{time.Second}, func() any { return cfg.Durations }},
+		{"labels", "--labels=a=b", "[]", map[string]string{"a": "b"}, func() any { return cfg.Labels }},
+		{"limits", "--limits=a=1", "[]", map[string]int{"a": 1}, func() any { return cfg.Limits }},
+		{"ip", "--ip=10.0.0.1", "<nil>", net.ParseIP("10.0.0.1"), func() any { return cfg.IP }},
+		{"mode", "--mode=slow", "fast", testMode{name: "SLOW"}, func() any { return cfg.Mode }},
+		{"server.port", "--server.port=443", "8080", 443, func() any { return cfg.Server.Port }},
+	}
+	flags := deploy.Flags()
+	args := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		f := flags.Lookup(tt.flag)
+		if f == nil {
+			t.Fatalf("Expected flag '%s' to be registered", tt.flag)
+		}
+		if f.DefValue != tt.defValue {
+			t.Errorf("Expected flag '%s' default '%s', got '%s'", tt.flag, tt.defValue, f.DefValue)
+		}
+		args = append(args, tt.arg)
+	}
+	if flags.Lookup("ignored") != nil {
+		t.Error("Expected no flag for a field with the `config:\"-\"` tag")
+	}
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			if value := tt.field(); !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+	if value := c.Viper().GetInt("deploy.server.port"); value != 443 {
+		t.Errorf("Expected the flag bound to 'deploy.server.port', got %d", value)
+	}
+}
+
+func TestRegisterFlagsUsage(t *testing.T) {
+	_, deploy, _ := newKeyTestCommands()
+	c := newSourceTestConfig(t, nil)
+	if err := c.RegisterPersistentFlags(deploy, &testFlagsConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		flag      string
+		shorthand string
+		usage     string
+	}{
+		{"string", "s", "A string [env TEST_DEPLOY_STRING]"},
+		{"server.port", "", "[env TEST_DEPLOY_SERVER_PORT]"},
+		{"mode", "", "[env TEST_DEPLOY_MODE]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			f := deploy.PersistentFlags().Lookup(tt.flag)
+			if f == nil {
+				t.Fatalf("Expected persistent flag '%s'", tt.flag)
+			}
+			if f.Shorthand != tt.shorthand || f.Usage != tt.usage {
+				t.Errorf("Expected -%s '%s', got -%s '%s'", tt.shorthand, tt.usage, f.Shorthand, f.Usage)
+			}
+		})
+	}
+	if mode := deploy.PersistentFlags().Lookup("mode"); mode.Value.Type() != "testMode" {
+		t.Errorf("Expected the type name of the text value, got '%s'", mode.Value.Type())
+	}
+}
+
+func TestRegisterFlagsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		err   string
+	}{
+		{"unsupported type", &struct{ Value complex64 }{}, "unsupported type complex64"},
+		{"invalid default", &struct {
+			Value int `default:"x"`
+		}{}, "invalid default 'x' for 'value'"},
+		{"not a pointer", struct{ Value int }{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, deploy, _ := newKeyTestCommands()
+			err := newSourceTestConfig(t, nil).RegisterFlags(deploy, tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSizeValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		str      string
+		err      bool
+	}{
+		{"100MB", 100 << 20, "100MB", false},
+		{"2 gb", 2 << 30, "2GB", false},
+		{"3072", 3072, "3KB", false},
+		{"1536", 1536, "1536B", false},
+		{"10b", 10, "10B", false},
+		{"0", 0, "0", false},
+		{"big", 0, "", true},
+		{"-1KB", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var size int64
+			var v pflag.Value = &sizeValue{size: &size}
+			err := v.Set(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected an error for '%s'", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tt.expected || v.String() != tt.str {
+				t.Errorf("Expected %d '%s', got %d '%s'", tt.expected, tt.str, size, v.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Converter turns a raw config value, as found in files, env variables or flags, into a value of a given type
+type Converter func(raw any) (any, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[reflect.Type]Converter{
+		reflect.TypeOf(url.URL{}):        convertString(func(s string) (any, error) { return urlValue(s) }),
+		reflect.TypeOf(&url.URL{}):       convertString(func(s string) (any, error) { return url.Parse(s) }),
+		reflect.TypeOf(&regexp.Regexp{}): convertString(func(s string) (any, error) { return regexp.Compile(s) }),
+		reflect.TypeOf(&time.Location{}): convertString(func(s string) (any, error) { return time.LoadLocation(s) }),
+	}
+)
+
+// RegisterConverter registers fn to convert raw config values to T for Get and GetE, replacing any previous converter
+// for T, built-in ones included
+func RegisterConverter[T any](fn func(raw any) (T, error)) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[reflect.TypeOf((*T)(nil)).Elem()] = func(raw any) (any, error) {
+		return fn(raw)
+	}
+}
+
+// Get returns the value of key, prefixed by cmd (see PrefixKey), as a T using the Default() Config.
+// The zero value is returned when the key is not set or cannot be converted, see GetE
+func Get[T any](cmd *cobra.Command, key string) T {
+	value, _ := GetE[T](cmd, key)
+	return value
+}
+
+// GetE returns the value of key, prefixed by cmd (see PrefixKey), as a T using the Default() Config.
+// See GetEWith
+func GetE[T any](cmd *cobra.Command, key string) (T, error) {
+	return GetEWith[T](Default(), cmd, key)
+}
+
+// GetWith is like Get, using c
+func GetWith[T any](c *Config, cmd *cobra.Command, key string) T {
+	value, _ := GetEWith[T](c, cmd, key)
+	return value
+}
+
+// GetEWith returns the value of key, prefixed by cmd (see PrefixKey), as a T using c.
+//
+// A registered converter is used first (see RegisterConverter), then the same weakly typed decoding as Decode,
+// which covers numbers, slices, maps, durations and encoding.TextUnmarshaler types like net.IP.
+// url.URL, *url.URL, *regexp.Regexp and *time.Location are supported out of the box.
+// An unset key returns the zero value without error.
+func GetEWith[T any](c *Config, cmd *cobra.Command, key string) (T, error) {
+	key = c.prefixKey(cmd, key)
+	c.mu.RLock()
+	raw := c.viper.Get(key)
+	c.mu.RUnlock()
+	return convertValue[T](key, raw)
+}
+
+// convertValue converts the raw value of key to a T, see GetEWith
+func convertValue[T any](key string, raw any) (T, error) {
+	var out T
+	if raw == nil {
+		return out, nil
+	}
+	if v, ok := raw.(T); ok {
+		return v, nil
+	}
+
+	convertersMu.RLock()
+	converter, ok := converters[reflect.TypeOf((*T)(nil)).Elem()]
+	convertersMu.RUnlock()
+	if ok {
+		v, err := converter(raw)
+		if err != nil {
+			return out, fmt.Errorf("cannot convert '%s' value '%v' to %T: %w", key, raw, out, err)
+		}
+		if out, ok = v.(T); !ok {
+			return out, fmt.Errorf("converter for %T returned %T for '%s'", out, v, key)
+		}
+		return out, nil
+	}
+
+	if err := decodeValue(raw, reflect.ValueOf(&out).Elem()); err != nil {
+		return out, fmt.Errorf("cannot convert '%s' value '%v' to %T: %w", key, raw, out, err)
+	}
+	return out, nil
+}
+
+// convertString returns a Converter parsing the string form of raw values with parse
+func convertString(parse func(s string) (any, error)) Converter {
+	return func(raw any) (any, error) {
+		s, ok := raw.(string)
+		if !ok {
+			s = fmt.Sprint(raw)
+		}
+		return parse(s)
+	}
+}
+
+func urlValue(s string) (url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return url.URL{}, err
+	}
+	return *u, nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func TestGet(t *testing.T) {
+	c, err := NewConfig(&Options{EnvPrefix: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := newTestCommand()
+	c.Viper().Set("deploy.size", "18446744073709551615")
+	c.Viper().Set("deploy.ports", "80,443")
+	c.Viper().Set("deploy.ip", "10.0.0.1")
+	c.Viper().Set("deploy.endpoint", "https://example.com/api")
+	c.Viper().Set("deploy.pattern", "^v[0-9]+$")
+	c.Viper().Set("deploy.zone", "UTC")
+	c.Viper().Set("deploy.level", "high")
+	c.Viper().Set("deploy.timeout", "1m")
+
+	tests := []struct {
+		name     string
+		get      func() (any, error)
+		expected any
+	}{
+		{"uint64", func() (any, error) { return GetEWith[uint64](c, cmd, "size") }, uint64(18446744073709551615)},
+		{"[]int", func() (any, error) { return GetEWith[[]int](c, cmd, "ports") }, []int{80, 443}},
+		{"net.IP", func() (any, error) { return GetEWith[net.IP](c, cmd, "ip") }, net.ParseIP("10.0.0.1")},
+		{"url.URL", func() (any, error) {
+			u, err := GetEWith[url.URL](c, cmd, "endpoint")
+			return u.Host, err
+		}, "example.com"},
+		{"*regexp.Regexp", func() (any, error) {
+			r, err := GetEWith[*regexp.Regexp](c, cmd, "pattern")
+			return r.MatchString("v12"), err
+		}, true},
+		{"*time.Location", func() (any, error) {
+			l, err := GetEWith[*time.Location](c, cmd, "zone")
+			return l.String(), err
+		}, "UTC"},
+		{"TextUnmarshaler", func() (any, error) { return GetEWith[testLevel](c, cmd, "level") }, testLevel(2)},
+		{"time.Duration", func() (any, error) { return GetEWith[time.Duration](c, cmd, "timeout") }, time.Minute},
+		{"unset", func() (any, error) { return GetEWith[int](c, cmd, "missing") }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestGetEConversionError(t *testing.T) {
+	c, err := NewConfig(&Options{EnvPrefix: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := newTestCommand()
+	c.Viper().Set("deploy.replicas", "many")
+
+	value, err := GetEWith[int](c, cmd, "replicas")
+	if err == nil || !strings.Contains(err.Error(), "deploy.replicas") {
+		t.Errorf("Expected a conversion error for deploy.replicas, got %v", err)
+	}
+	if value != 0 || GetWith[int](c, cmd, "replicas") != 0 {
+		t.Errorf("Expected zero value, got %d", value)
+	}
+}
+
+func TestRegisterConverter(t *testing.T) {
+	type csv []string
+	RegisterConverter(func(raw any) (csv, error) {
+		return strings.Split(raw.(string), ";"), nil
+	})
+	c, err := NewConfig(&Options{EnvPrefix: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := newTestCommand()
+	c.Viper().Set("deploy.hosts", "a;b")
+
+	hosts, err := GetEWith[csv](c, cmd, "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hosts, csv{"a", "b"}) {
+		t.Errorf("Expected [a b], got %v", hosts)
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/file"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// dropInDir returns the `<ConfigName>.d` directory for path. For a path that is a file,
+// it is the sibling directory named after the file without its extension
+func (opts *Options) dropInDir(path string) string {
+	if file.IsFile(path) {
+		return stringutil.ConcatStrings(file.TrimExtension(path), ".d")
+	}
+	return filepath.Join(path, stringutil.ConcatStrings(opts.ConfigName, ".d"))
+}
+
+// dropInFiles returns the files with a supported extension in the drop-in directory of path, in lexical order
+func (opts *Options) dropInFiles(path string) []string {
+	dir := opts.dropInDir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !opts.hasConfigExt(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files
+}
+
+// hasConfigExt returns true if name has one of configExts
+func (opts *Options) hasConfigExt(name string) bool {
+	return containsString(opts.configExts(), strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// readIncludeLayers returns the layers of the files included by configFile, followed by configFile itself.
+//
+// The `include` key holds a path or a list of paths, relative to the directory of configFile,
+// that may be glob patterns.
+// Included files can include other files, chain holds the including files so cycles are detected.
+// Includes that cannot be read are recorded in errs like the config path p they come from, and skipped
+func (opts *Options) readIncludeLayers(
+	configFile string, settings map[string]interface{}, chain []string, p configPath, errs *loadErrors,
+) []configLayer {
+	chain = append(chain[:len(chain):len(chain)], absPath(configFile))
+	includes, err := includePaths(configFile, settings[defaults.IncludeKey])
+	if err != nil {
+		errs.add(p, &FileError{File: configFile, Err: err}, false)
+	}
+	delete(settings, defaults.IncludeKey)
+
+	layers := make([]configLayer, 0, len(includes)+1)
+	for _, include := range includes {
+		if containsString(chain, include) {
+			errs.add(p, &FileError{
+				File: configFile,
+				Err:  fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), include),
+			}, false)
+			continue
+		}
+		includeSettings, err := opts.readSettings(include)
+		if err != nil {
+			errs.add(p, asFileError(include, fmt.Errorf("failed to include from '%s': %w", configFile, err)), false)
+			continue
+		}
+		logger.Debugf("included config file '%s' from '%s'", include, configFile)
+		layers = append(layers, opts.readIncludeLayers(include, includeSettings, chain, p, errs)...)
+	}
+	return append(layers, configLayer{source: configFile, settings: settings})
+}
+
+// includePaths returns the absolute paths of the include directive value, expanding glob patterns in lexical order.
+// A pattern matching nothing is ignored, while a missing plain path is an error returned along with the other paths
+func includePaths(configFile string, value interface{}) ([]string, error) {
+	var patterns []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		patterns = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid '%s' item '%v' in '%s', expected a path", defaults.IncludeKey, item, configFile)
+			}
+			patterns = append(patterns, s)
+		}
+	default:
+		return nil, fmt.Errorf("invalid '%s' value '%v' in '%s', expected a path or a list of paths",
+			defaults.IncludeKey, value, configFile)
+	}
+
+	paths := make([]string, 0, len(patterns))
+	var errs []error
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configFile), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid '%s' pattern '%s' in '%s': %w",
+				defaults.IncludeKey, pattern, configFile, err))
+			continue
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			errs = append(errs, fmt.Errorf("included config file '%s' from '%s' does not exist", pattern, configFile))
+			continue
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			paths = append(paths, absPath(m))
+		}
+	}
+	return paths, errors.Join(errs...)
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thedataflows/go-commons/pkg/lang"
+)
+
+func TestIncludes(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are written relative to a temporary directory holding app.yaml
+		files    map[string]string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name: "single include",
+			files: map[string]string{
+				"app.yaml":    "include: base.yaml\nname: app\n",
+				"base.yaml":   "name: base\nport: '80'\n",
+				"unused.yaml": "port: '1'\n",
+			},
+			expected: map[string]string{"name": "app", "port": "80"},
+		},
+		{
+			name: "list and glob in lexical order",
+			files: map[string]string{
+				"app.yaml":       "include: [conf/*.yaml, extra.yaml]\n",
+				"conf/b.yaml":    "name: b\n",
+				"conf/a.yaml":    "name: a\nport: '1'\n",
+				"extra.yaml":     "port: '2'\n",
+				"conf/skip.json": `{"name": "json"}`,
+			},
+			expected: map[string]string{"name": "b", "port": "2"},
+		},
+		{
+			name: "nested include",
+			files: map[string]string{
+				"app.yaml":     "include: sub/one.yaml\n",
+				"sub/one.yaml": "include: two.yaml\nname: one\n",
+				"sub/two.yaml": "name: two\nport: '2'\n",
+			},
+			expected: map[string]string{"name": "one", "port": "2"},
+		},
+		{
+			name:     "glob matching nothing",
+			files:    map[string]string{"app.yaml": "include: conf/*.yaml\nname: app\n"},
+			expected: map[string]string{"name": "app", "include": ""},
+		},
+		{
+			name: "drop-in files",
+			files: map[string]string{
+				"app.yaml":         "name: app\nport: '1'\n",
+				"app.d/10-a.yaml":  "port: '10'\n",
+				"app.d/20-b.yaml":  "port: '20'\n",
+				"app.d/notes.txt":  "port: 30\n",
+				"app.d/sub/c.yaml": "port: '40'\n",
+			},
+			expected: map[string]string{"name": "app", "port": "20"},
+		},
+		// broken includes fail only in strict mode, otherwise they are skipped like broken config files
+		{
+			name: "missing file",
+			files: map[string]string{
+				"app.yaml":  "include: [missing.yaml, base.yaml]\nname: app\n",
+				"base.yaml": "port: '1'\n",
+			},
+			expected: map[string]string{"name": "app", "port": "1"},
+			err:      "does not exist",
+		},
+		{
+			name: "unparsable file",
+			files: map[string]string{
+				"app.yaml":  "include: [bad.yaml, base.yaml]\nname: app\n",
+				"bad.yaml":  "a: [\n",
+				"base.yaml": "port: '1'\n",
+			},
+			expected: map[string]string{"name": "app", "port": "1"},
+			err:      "bad.yaml",
+		},
+		{
+			name:     "cycle",
+			files:    map[string]string{"app.yaml": "include: a.yaml\n", "a.yaml": "include: app.yaml\nname: a\n"},
+			expected: map[string]string{"name": "a"},
+			err:      "include cycle",
+		},
+		{
+			name:     "invalid value",
+			files:    map[string]string{"app.yaml": "include: 1\nname: app\n"},
+			expected: map[string]string{"name": "app"},
+			err:      "expected a path or a list of paths",
+		},
+		{
+			name:     "invalid item",
+			files:    map[string]string{"app.yaml": "include: [1]\nname: app\n"},
+			expected: map[string]string{"name": "app"},
+			err:      "expected a path",
+		},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s strict %v", tt.name, strict), func(t *testing.T) {
+				testIncludes(t, tt.files, strict, tt.expected, lang.If(strict, tt.err, ""))
+			})
+		}
+	}
+}
+
+func testIncludes(t *testing.T, files map[string]string, strict bool, expected map[string]string, expectedErr string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+	c := newSourceTestConfig(t, []string{filepath.Join(dir, "app.yaml")}, WithStrict(strict))
+	err := c.InitConfig()
+	if expectedErr != "" {
+		if err == nil || !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("Expected an error containing '%s', got %v", expectedErr, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range expected {
+		if actual := c.Viper().GetString(key); actual != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, actual)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+var (
+	// logger is the logger of this package, see log.Component
+	logger = log.Component("config")
+	// viperLogger is the logger of viper's internal messages
+	viperLogger = log.Component("viper")
+)
+
+// newViper returns a new viper instance that logs through our log package.
+// The handler looks up the `viper` component logger on every message, so level and format changes apply
+// without replacing the instance
+func newViper() *viper.Viper {
+	return viper.NewWithOptions(viper.WithLogger(slog.New(&viperLogHandler{})))
+}
+
+// viperLogHandler is a slog.Handler forwarding viper's internal messages to the `viper` component logger.
+//
+// Viper is chatty at info level, so anything below warning is logged at debug level.
+type viperLogHandler struct {
+	attrs []slog.Attr
+}
+
+func (h *viperLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return viperLogLevel(level) >= viperLogger.GetLogger().GetLevel()
+}
+
+func (h *viperLogHandler) Handle(_ context.Context, record slog.Record) error {
+	event := viperLogger.GetLogger().WithLevel(viperLogLevel(record.Level))
+	for _, a := range h.attrs {
+		event = event.Interface(a.Key, a.Value.Any())
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		event = event.Interface(a.Key, a.Value.Any())
+		return true
+	})
+	event.Msg(record.Message)
+	return nil
+}
+
+func (h *viperLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &viperLogHandler{attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *viperLogHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+func viperLogLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return log.ErrorLevel
+	case level >= slog.LevelWarn:
+		return log.WarnLevel
+	default:
+		return log.DebugLevel
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+func TestViperLogger(t *testing.T) {
+	events := &jsonEventsWriter{}
+	log.RegisterLogFormat("events", func(io.Writer, bool) io.Writer { return events })
+	defer func() {
+		_ = log.SetLogFormat(log.FormatConsole)
+		_ = log.SetLogLevel(log.InfoLevel.String())
+	}()
+	if err := log.SetLogFormat("events"); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.SetLogLevel("warn,viper=debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, v := range map[string]*viper.Viper{"default": Default().Viper(), "new": newViper()} {
+		t.Run(name, func(t *testing.T) {
+			events.messages = nil
+			v.AddConfigPath(t.TempDir())
+			for _, message := range events.messages {
+				if message == "adding path to search paths" {
+					return
+				}
+			}
+			t.Errorf("Expected viper messages to be logged, got %q", events.messages)
+		})
+	}
+
+	// the instances follow level changes
+	if err := log.SetLogLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	events.messages = nil
+	Default().Viper().AddConfigPath(t.TempDir())
+	if len(events.messages) > 0 {
+		t.Errorf("Expected no viper debug messages at warn level, got %q", events.messages)
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/file"
+)
+
+// MigrationFunc upgrades settings, read from one config file, to the version it is registered for.
+// It returns the old keys it replaced or dropped, reported as deprecated. Keys that are missing must be ignored,
+// as files often hold a part of the config only
+type MigrationFunc func(settings map[string]interface{}) (deprecatedKeys []string, err error)
+
+// Migration upgrades config files to Version
+type Migration struct {
+	Version int
+	Migrate MigrationFunc
+}
+
+// WithMigration registers fn to upgrade config files to version. The `version` key of a config file,
+// 0 when missing, tells which migrations it needs: all the ones with a greater version, run in increasing order.
+// The profile sections of a file are migrated along with it.
+//
+// Each file is migrated on its own, before the files are merged, since each one has its own version:
+// the keys of a file already at a version are never migrated again because an older file is merged with it.
+//
+// The `version` key is reserved as soon as a migration is registered, and removed from the settings.
+func WithMigration(version int, fn MigrationFunc) Option {
+	return func(o *Options) {
+		o.Migrations = append(o.Migrations, Migration{Version: version, Migrate: fn})
+		sort.SliceStable(o.Migrations, func(i, j int) bool { return o.Migrations[i].Version < o.Migrations[j].Version })
+	}
+}
+
+// WithMigrationRewrite rewrites migrated local config files in place, with the latest version.
+// Comments and key order are not preserved
+func WithMigrationRewrite(rewrite bool) Option {
+	return func(o *Options) {
+		o.MigrationRewrite = rewrite
+	}
+}
+
+// RenameKey returns a MigrationFunc moving the value of the dotted oldKey to newKey,
+// unless newKey is set already. Empty parent sections of oldKey are removed
+func RenameKey(oldKey, newKey string) MigrationFunc {
+	return func(settings map[string]interface{}) ([]string, error) {
+		value, ok := deleteSetting(settings, strings.ToLower(oldKey))
+		if !ok {
+			return nil, nil
+		}
+		if settingAt(settings, strings.ToLower(newKey)) == nil {
+			setSetting(settings, strings.ToLower(newKey), value)
+		}
+		return []string{oldKey}, nil
+	}
+}
+
+// ConfigVersion returns the latest version of the registered migrations, 0 if none
+func (opts *Options) ConfigVersion() int {
+	if len(opts.Migrations) == 0 {
+		return 0
+	}
+	return opts.Migrations[len(opts.Migrations)-1].Version
+}
+
+// readSettings returns the settings of configFile, migrated to ConfigVersion
+func (opts *Options) readSettings(configFile string) (map[string]interface{}, error) {
+	settings, err := readConfigFile(configFile, opts.ConfigType)
+	if err != nil {
+		return nil, err
+	}
+	return settings, opts.migrate(configFile, settings)
+}
+
+// migrate runs on settings, read from source, the migrations it needs, see WithMigration
+func (opts *Options) migrate(source string, settings map[string]interface{}) error {
+	if len(opts.Migrations) == 0 {
+		return nil
+	}
+	version, err := settingsVersion(settings[defaults.VersionKey])
+	if err != nil {
+		return &FileError{File: source, Err: err}
+	}
+	delete(settings, defaults.VersionKey)
+
+	sections := map[string]map[string]interface{}{source: settings}
+	if profiles, ok := settings[defaults.ProfilesKey].(map[string]interface{}); ok {
+		for name, section := range profiles {
+			if m, ok := section.(map[string]interface{}); ok {
+				sections[fmt.Sprintf("%s#%s.%s", source, defaults.ProfilesKey, name)] = m
+			}
+		}
+	}
+	migrated := false
+	for _, m := range opts.Migrations {
+		if m.Version <= version {
+			continue
+		}
+		for sectionSource, section := range sections {
+			deprecatedKeys, err := m.Migrate(section)
+			if err != nil {
+				return &FileError{File: source, Err: fmt.Errorf("migration to version %d failed: %w", m.Version, err)}
+			}
+			for _, k := range deprecatedKeys {
+				logger.Warnf("config key '%s' in '%s' is deprecated, migrated to version %d", k, sectionSource, m.Version)
+			}
+		}
+		migrated = true
+	}
+	if !migrated || !opts.MigrationRewrite || !file.IsFile(source) {
+		return nil
+	}
+
+	rewritten := make(map[string]interface{}, len(settings)+1)
+	for k, v := range settings {
+		rewritten[k] = v
+	}
+	rewritten[defaults.VersionKey] = opts.ConfigVersion()
+	if err = writeConfigFile(source, rewritten, false); err != nil {
+		return &FileError{File: source, Err: fmt.Errorf("failed to rewrite migrated config: %w", err)}
+	}
+	logger.Infof("rewrote config file '%s' to version %d", source, opts.ConfigVersion())
+	return nil
+}
+
+// settingsVersion parses the `version` key value, 0 when missing
+func settingsVersion(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	case string:
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid '%s' '%s': %w", defaults.VersionKey, v, err)
+		}
+		return version, nil
+	}
+	return 0, fmt.Errorf("invalid '%s' '%v'", defaults.VersionKey, value)
+}
+
+// deleteSetting removes the dotted key from nested settings, along with the parent sections left empty
+func deleteSetting(settings map[string]interface{}, key string) (interface{}, bool) {
+	parent, leaf, ok := strings.Cut(key, ".")
+	if !ok {
+		value, exists := settings[key]
+		delete(settings, key)
+		return value, exists
+	}
+	sub, isMap := settings[parent].(map[string]interface{})
+	if !isMap {
+		return nil, false
+	}
+	value, exists := deleteSetting(sub, leaf)
+	if len(sub) == 0 {
+		delete(settings, parent)
+	}
+	return value, exists
+}
+
+// setSetting sets the dotted key in nested settings, creating the missing sections
+func setSetting(settings map[string]interface{}, key string, value interface{}) {
+	segments := strings.Split(key, ".")
+	m := settings
+	for _, segment := range segments[:len(segments)-1] {
+		sub, ok := m[segment].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[segment] = sub
+		}
+		m = sub
+	}
+	m[segments[len(segments)-1]] = value
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigration(t *testing.T) {
+	dir := t.TempDir()
+	configFile := writeTestFile(t, filepath.Join(dir, "app.yaml"),
+		"timeout: 5s\nserver:\n  addr: localhost\nprofiles:\n  prod:\n    timeout: 10s\n")
+	writeTestFile(t, filepath.Join(dir, "app.d", "10-current.yaml"), "version: 2\nhttp:\n  timeout: 1s\n")
+
+	options := []Option{
+		WithConfigName("app"),
+		WithProfile("prod"),
+		WithMigration(2, func(settings map[string]interface{}) ([]string, error) {
+			return RenameKey("server.addr", "http.host")(settings)
+		}),
+		WithMigration(1, RenameKey("timeout", "http.timeout")),
+	}
+	c := newSourceTestConfig(t, []string{dir}, options...)
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"http.timeout": "10s", "http.host": "localhost", "timeout": "", "server.addr": ""}
+	for k, v := range expected {
+		if value := c.Viper().GetString(k); value != v {
+			t.Errorf("Expected %s to be '%s', got '%s'", k, v, value)
+		}
+	}
+	if c.Viper().IsSet("version") {
+		t.Error("Expected version to be removed from the settings")
+	}
+
+	c = newSourceTestConfig(t, []string{dir}, append(options, WithMigrationRewrite(true))...)
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"version: 2", "host: localhost", "timeout: 10s"} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("Expected the rewritten file to contain '%s', got:\n%s", s, content)
+		}
+	}
+	if strings.Contains(string(content), "addr") {
+		t.Errorf("Expected the rewritten file to not contain 'addr', got:\n%s", content)
+	}
+}
+
+func TestMigrationPerFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.yaml"), "timeout: 5s\n")
+	// written for version 1, where `timeout` is a new key that must not be migrated again
+	writeTestFile(t, filepath.Join(dir, "app.d", "10-current.yaml"), "version: 1\ntimeout: 1m\n")
+
+	c := newSourceTestConfig(t, []string{dir},
+		WithConfigName("app"), WithMigration(1, RenameKey("timeout", "http.timeout")))
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"http.timeout": "5s", "timeout": "1m"}
+	for k, v := range expected {
+		if value := c.Viper().GetString(k); value != v {
+			t.Errorf("Expected %s to be '%s', got '%s'", k, v, value)
+		}
+	}
+}
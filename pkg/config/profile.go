@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/file"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// WithProfile selects the profile overlaid on top of the base config. It can also be set with the --profile flag
+// or the <EnvPrefix>_PROFILE env variable, the flag taking precedence.
+//
+// The precedence, from lowest to highest, is:
+//  1. base config files, in UserConfigPaths order
+//  2. the `profiles.<profile>` section of each base config file, in the same order
+//  3. `<ConfigName>.<profile>.<ext>` files, in UserConfigPaths order. For a path that is a file,
+//     the profile file is its sibling with the profile inserted before the extension
+//  4. environment variables, then flags, as for any other key
+//
+// The `profiles` section is reserved and removed from the base layers, even when no profile is active.
+func WithProfile(profile string) Option {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+func WithProfileKey(profileKey string) Option {
+	return func(o *Options) {
+		o.ProfileKey = profileKey
+	}
+}
+
+// ActiveProfile returns Profile if set (usually by the --profile flag), otherwise the <EnvPrefix>_PROFILE env variable
+func (opts *Options) ActiveProfile() string {
+	if opts.Profile != "" {
+		return opts.Profile
+	}
+	if opts.ProfileKey == "" {
+		return ""
+	}
+	return os.Getenv(opts.buildEnvKey(nil, opts.EnvPrefix, opts.ProfileKey))
+}
+
+// readProfileLayers appends the layers of the active profile after the base layers, see WithProfile
+func (opts *Options) readProfileLayers(base []configLayer, errs *loadErrors) ([]configLayer, error) {
+	sections := make([]configLayer, 0, len(base))
+	profile := opts.ActiveProfile()
+	for _, layer := range base {
+		profiles, ok := layer.settings[defaults.ProfilesKey].(map[string]interface{})
+		delete(layer.settings, defaults.ProfilesKey)
+		if !ok || profile == "" {
+			continue
+		}
+		if section, ok := profiles[strings.ToLower(profile)].(map[string]interface{}); ok {
+			logger.Debugf("merged profile section '%s.%s' from '%s'", defaults.ProfilesKey, profile, layer.source)
+			sections = append(sections, configLayer{
+				source:    fmt.Sprintf("%s#%s.%s", layer.source, defaults.ProfilesKey, profile),
+				settings:  section,
+				untrusted: layer.untrusted,
+			})
+		}
+	}
+	if profile == "" {
+		return base, nil
+	}
+	if strings.ContainsAny(profile, `/\`) {
+		return nil, fmt.Errorf("invalid profile name '%s'", profile)
+	}
+
+	layers := append(base, sections...)
+	found := len(sections) > 0
+	for _, p := range opts.configPaths() {
+		if opts.isSource(p.path) {
+			continue
+		}
+		profileFile := opts.findProfileFile(p.path, profile)
+		if profileFile == "" {
+			continue
+		}
+		settings, err := opts.readSettings(profileFile)
+		if err != nil {
+			errs.add(p, asFileError(profileFile, err), false)
+			continue
+		}
+		logger.Debugf("merged profile config file '%s'", profileFile)
+		layers = append(layers, opts.readIncludeLayers(profileFile, settings, nil, p, errs)...)
+		found = true
+	}
+	if !found {
+		logger.Warnf("no config found for profile '%s'", profile)
+	}
+	return layers, nil
+}
+
+// findProfileFile returns the profile file matching path, which is either a config file or a directory
+func (opts *Options) findProfileFile(path, profile string) string {
+	if file.IsFile(path) {
+		profileFile := stringutil.ConcatStrings(file.TrimExtension(path), ".", profile, filepath.Ext(path))
+		if file.IsFile(profileFile) {
+			return profileFile
+		}
+		return ""
+	}
+	for _, ext := range opts.configExts() {
+		profileFile := filepath.Join(path, stringutil.ConcatStrings(opts.ConfigName, ".", profile, ".", ext))
+		if file.IsFile(profileFile) {
+			return profileFile
+		}
+	}
+	return ""
+}
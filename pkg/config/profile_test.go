@@ -0,0 +1,110 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	base := `name: base
+port: '1'
+profiles:
+  dev:
+    port: '2'
+    debug: 'true'
+`
+	tests := []struct {
+		name    string
+		files   map[string]string
+		profile string
+		// env is the value of TEST_PROFILE
+		env string
+		// dir uses the temporary directory as the config path instead of app.yaml
+		dir      bool
+		expected map[string]string
+		err      string
+	}{
+		{
+			name:     "no profile",
+			files:    map[string]string{"app.yaml": base, "app.dev.yaml": "name: dev\n"},
+			expected: map[string]string{"name": "base", "port": "1", "debug": ""},
+		},
+		{
+			name:     "section",
+			files:    map[string]string{"app.yaml": base},
+			profile:  "dev",
+			expected: map[string]string{"name": "base", "port": "2", "debug": "true"},
+		},
+		{
+			name:     "profile file over section",
+			files:    map[string]string{"app.yaml": base, "app.dev.yaml": "port: '3'\n"},
+			profile:  "dev",
+			expected: map[string]string{"name": "base", "port": "3", "debug": "true"},
+		},
+		{
+			name:     "profile file in directory",
+			files:    map[string]string{"app.yaml": base, "app.dev.json": `{"name": "dev"}`},
+			profile:  "dev",
+			dir:      true,
+			expected: map[string]string{"name": "dev", "port": "2"},
+		},
+		{
+			name:     "env",
+			files:    map[string]string{"app.yaml": base},
+			env:      "dev",
+			expected: map[string]string{"port": "2"},
+		},
+		{
+			name:     "option over env",
+			files:    map[string]string{"app.yaml": base, "app.prod.yaml": "port: '4'\n"},
+			profile:  "prod",
+			env:      "dev",
+			expected: map[string]string{"port": "4", "debug": ""},
+		},
+		{
+			name:     "unknown profile",
+			files:    map[string]string{"app.yaml": base},
+			profile:  "missing",
+			expected: map[string]string{"port": "1"},
+		},
+		{
+			name:    "invalid profile",
+			files:   map[string]string{"app.yaml": base},
+			profile: "../dev",
+			err:     "invalid profile name '../dev'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_PROFILE", tt.env)
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			path := filepath.Join(dir, "app.yaml")
+			if tt.dir {
+				path = dir
+			}
+			c := newSourceTestConfig(t, []string{path}, WithConfigName("app"), WithProfile(tt.profile))
+			err := c.InitConfig()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, expected := range tt.expected {
+				if value := c.Viper().GetString(key); value != expected {
+					t.Errorf("Expected %s '%s', got '%s'", key, expected, value)
+				}
+			}
+			if c.Viper().IsSet("profiles") {
+				t.Error("Expected the profiles section to be removed")
+			}
+		})
+	}
+}
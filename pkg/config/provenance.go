@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// Sources a config value can come from, in viper's order of precedence
+const (
+	SourceOverride = "override"
+	SourceFlag     = "flag"
+	SourceEnv      = "env"
+	SourceFile     = "file"
+	SourceDefault  = "default"
+)
+
+// Provenance tells where the effective value of a key came from
+type Provenance struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	// Source is one of SourceOverride, SourceFlag, SourceEnv, SourceFile or SourceDefault
+	Source string `json:"source"`
+	// Detail is the flag, the environment variable or the file path the value came from
+	Detail string `json:"detail,omitempty"`
+}
+
+// Explain returns the provenance of every known key, sorted by key. Secret values are masked
+func (c *Config) Explain() []Provenance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := c.viper.AllKeys()
+	sort.Strings(keys)
+	provenance := make([]Provenance, 0, len(keys))
+	for _, k := range keys {
+		provenance = append(provenance, c.explainKey(k))
+	}
+	return provenance
+}
+
+// ExplainKey returns the provenance of key, following viper's precedence:
+// override, flag, env, config file, default. Flags that were not changed count as defaults
+func (c *Config) ExplainKey(key string) Provenance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.explainKey(key)
+}
+
+// explainKey is ExplainKey, with c.mu held
+func (c *Config) explainKey(key string) Provenance {
+	key = strings.ToLower(key)
+	p := Provenance{
+		Key:    key,
+		Value:  c.viper.Get(key),
+		Source: SourceDefault,
+	}
+	if c.IsSecret(key) || c.hasSecretItems(key) {
+		p.Value = SecretMask
+	}
+
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	flag := c.bindings[key]
+	switch {
+	case c.overrides[key]:
+		p.Source = SourceOverride
+	case flag != nil && flag.Changed:
+		p.Source, p.Detail = SourceFlag, stringutil.ConcatStrings("--", flag.Name)
+	case c.envIsSet(key):
+		p.Source, p.Detail = SourceEnv, c.envKey(nil, key)
+	case c.origins[key] != "":
+		p.Source, p.Detail = SourceFile, c.origins[key]
+	case flag != nil:
+		p.Detail = stringutil.ConcatStrings("--", flag.Name)
+	}
+	return p
+}
+
+// envIsSet returns true if the environment variable viper looks up for key is set and not empty
+func (c *Config) envIsSet(key string) bool {
+	return os.Getenv(c.envKey(nil, key)) != ""
+}
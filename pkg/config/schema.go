@@ -0,0 +1,541 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/log"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaDraft is the JSON Schema dialect generated by JSONSchema
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// SchemaValidation tells InitConfig what to do with config files not matching JSONSchema
+type SchemaValidation int
+
+const (
+	// SchemaValidationOff skips the validation
+	SchemaValidationOff SchemaValidation = iota
+	// SchemaValidationWarn logs every violation as a warning
+	SchemaValidationWarn
+	// SchemaValidationError fails InitConfig, or rejects a reload, with a *SchemaError
+	SchemaValidationError
+)
+
+func WithSchemaValidation(schemaValidation SchemaValidation) Option {
+	return func(o *Options) {
+		o.SchemaValidation = schemaValidation
+	}
+}
+
+// Schema is the subset of JSON Schema describing config keys
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *float64           `json:"minLength,omitempty"`
+	MaxLength            *float64           `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *float64           `json:"minItems,omitempty"`
+	MaxItems             *float64           `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+}
+
+// SchemaViolation is a config file value not matching the schema. Line is 0 when unknown
+type SchemaViolation struct {
+	Key     string
+	File    string
+	Line    int
+	Message string
+}
+
+func (v SchemaViolation) Error() string {
+	location := v.File
+	if v.Line > 0 {
+		location = fmt.Sprintf("%s:%d", v.File, v.Line)
+	}
+	if location == "" {
+		return fmt.Sprintf("%s: %s", v.Key, v.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, v.Key, v.Message)
+}
+
+// SchemaError aggregates all the violations found in the config files
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	var strBuilder strings.Builder
+	strBuilder.WriteString("config does not match schema:")
+	for _, v := range e.Violations {
+		strBuilder.WriteString("\n  ")
+		strBuilder.WriteString(v.Error())
+	}
+	return strBuilder.String()
+}
+
+// RegisterSchema makes the fields of aStruct, prefixed by cmd, part of JSONSchema, using the Default() Config
+func RegisterSchema(cmd *cobra.Command, aStruct any) error {
+	return Default().RegisterSchema(cmd, aStruct)
+}
+
+// RegisterSchema makes the fields of aStruct, prefixed by cmd, part of JSONSchema.
+// Structs passed to RegisterFlags and Decode are registered already
+func (c *Config) RegisterSchema(cmd *cobra.Command, aStruct any) error {
+	fields, err := structKeyFields(aStruct)
+	if err != nil {
+		return err
+	}
+	c.registerSchemaFields(cmd, fields)
+	return nil
+}
+
+func (c *Config) registerSchemaFields(cmd *cobra.Command, fields []structKeyField) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.schemaFields == nil {
+		c.schemaFields = make(map[string]reflect.StructField)
+	}
+	for _, f := range fields {
+		c.schemaFields[strings.ToLower(c.prefixKey(cmd, f.key))] = f.field
+	}
+}
+
+// JSONSchema returns the schema of the config, built from the Options flags, the flags bound through this package
+// and the registered structs, the latter taking precedence. Objects do not allow unknown keys, except maps
+func (c *Config) JSONSchema() *Schema {
+	root := &Schema{Schema: SchemaDraft, Type: "object", AdditionalProperties: false}
+	schemaNode(root, defaults.VersionKey, &Schema{
+		Type:        "integer",
+		Description: "Version of the config format, see WithMigration",
+	})
+	if c.opts != nil && c.opts.Flags != nil {
+		c.opts.Flags.VisitAll(func(flag *pflag.Flag) {
+			if flag.Name == "config" || flag.Name == c.opts.ProfileKey {
+				return
+			}
+			schemaNode(root, strings.ToLower(flag.Name), flagSchema(flag))
+		})
+		if levels, ok := root.Properties[c.opts.LogLevelKey]; ok {
+			levels.Pattern = log.LevelSpecPattern()
+		}
+		if formats, ok := root.Properties[c.opts.LogFormatKey]; ok {
+			formats.Enum = enumValues(formats.Type, log.Formats())
+		}
+	}
+
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	for key, flag := range c.bindings {
+		schemaNode(root, key, flagSchema(flag))
+	}
+	for key, field := range c.schemaFields {
+		schemaNode(root, key, fieldSchema(field))
+	}
+	return root
+}
+
+// schemaNode sets leaf at the dotted key under root, creating the intermediate objects
+func schemaNode(root *Schema, key string, leaf *Schema) {
+	segments := strings.Split(key, ".")
+	node := root
+	for _, s := range segments[:len(segments)-1] {
+		child, ok := node.Properties[s]
+		if !ok || child.Type != "object" || child.Properties == nil {
+			child = &Schema{Type: "object", AdditionalProperties: false, Properties: map[string]*Schema{}}
+		}
+		if node.Properties == nil {
+			node.Properties = map[string]*Schema{}
+		}
+		node.Properties[s] = child
+		node = child
+	}
+	if node.Properties == nil {
+		node.Properties = map[string]*Schema{}
+	}
+	node.Properties[segments[len(segments)-1]] = leaf
+}
+
+// flagSchema maps the pflag value types to schema types
+func flagSchema(flag *pflag.Flag) *Schema {
+	s := &Schema{Description: flag.Usage}
+	flagType := flag.Value.Type()
+	switch {
+	case flagType == "bool":
+		s.Type = "boolean"
+	case flagType == "duration":
+		s.Type, s.Format = "string", "duration"
+	case flagType == "ip":
+		s.Type, s.Format = "string", "ip"
+	case flagType == "size":
+		// a number of bytes, or a string with a unit
+		s.Pattern = `^\s*[0-9]+\s*([kKmMgG]?[bB])?\s*$`
+	case strings.HasPrefix(flagType, "int") || strings.HasPrefix(flagType, "uint"):
+		if strings.HasSuffix(flagType, "Slice") {
+			s.Type, s.Items = "array", &Schema{Type: "integer"}
+		} else {
+			s.Type = "integer"
+		}
+	case strings.HasPrefix(flagType, "float"):
+		if strings.HasSuffix(flagType, "Slice") {
+			s.Type, s.Items = "array", &Schema{Type: "number"}
+		} else {
+			s.Type = "number"
+		}
+	case flagType == "boolSlice":
+		s.Type, s.Items = "array", &Schema{Type: "boolean"}
+	case flagType == "durationSlice":
+		s.Type, s.Items = "array", &Schema{Type: "string", Format: "duration"}
+	case strings.HasSuffix(flagType, "Slice") || strings.HasSuffix(flagType, "Array"):
+		s.Type, s.Items = "array", &Schema{Type: "string"}
+	case flagType == "stringToString":
+		s.Type, s.AdditionalProperties = "object", &Schema{Type: "string"}
+	case flagType == "stringToInt" || flagType == "stringToInt64":
+		s.Type, s.AdditionalProperties = "object", &Schema{Type: "integer"}
+	default:
+		s.Type = "string"
+	}
+	if flag.DefValue != "" && flag.DefValue != "[]" && flag.DefValue != "0" && flag.DefValue != "false" {
+		s.Default = typedDefault(s.Type, flag.DefValue)
+	}
+	return s
+}
+
+// typedDefault converts a default value to the schema type, so numbers and booleans are not quoted
+func typedDefault(schemaType, value string) interface{} {
+	switch schemaType {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// fieldSchema maps a config struct field and its tags to a schema
+func fieldSchema(field reflect.StructField) *Schema {
+	s := typeSchema(field.Type)
+	s.Description = field.Tag.Get(TagUsage)
+	if defaultValue, ok := field.Tag.Lookup(TagDefault); ok {
+		s.Default = typedDefault(s.Type, defaultValue)
+	}
+	if oneOf, ok := field.Tag.Lookup(TagOneOf); ok {
+		if s.Type == "array" && s.Items != nil {
+			s.Items.Enum = enumValues(s.Items.Type, strings.Fields(oneOf))
+		} else {
+			s.Enum = enumValues(s.Type, strings.Fields(oneOf))
+		}
+	}
+	if n, err := strconv.ParseFloat(field.Tag.Get(TagMin), 64); err == nil {
+		setSchemaBound(s, n, true)
+	}
+	if n, err := strconv.ParseFloat(field.Tag.Get(TagMax), 64); err == nil {
+		setSchemaBound(s, n, false)
+	}
+	return s
+}
+
+// enumValues converts the oneof values to the schema type, so numbers are not quoted
+func enumValues(schemaType string, values []string) []interface{} {
+	enum := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		n, err := strconv.ParseFloat(v, 64)
+		if (schemaType == "integer" || schemaType == "number") && err == nil {
+			enum = append(enum, n)
+			continue
+		}
+		enum = append(enum, v)
+	}
+	return enum
+}
+
+// setSchemaBound sets the min or max tag on the schema keyword matching the type. Durations are not expressible
+func setSchemaBound(s *Schema, n float64, isMin bool) {
+	switch s.Type {
+	case "integer", "number":
+		if isMin {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	case "string":
+		if s.Format == "duration" {
+			return
+		}
+		if isMin {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	case "array":
+		if isMin {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+	}
+}
+
+func typeSchema(t reflect.Type) *Schema {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return &Schema{Type: "string", Format: "duration"}
+	case t == reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(net.IP{}):
+		return &Schema{Type: "string", Format: "ip"}
+	case t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Interface:
+		return &Schema{}
+	}
+	return &Schema{Type: "string"}
+}
+
+// ValidateSettings checks settings, a config file layer, against JSONSchema.
+// origins maps dotted keys to the file they came from, used to report the file and line of each violation
+func (c *Config) ValidateSettings(settings map[string]interface{}, origins map[string]string) []SchemaViolation {
+	violations := make([]SchemaViolation, 0)
+	validateSchema(c.JSONSchema(), "", settings, func(key, message string) {
+		v := SchemaViolation{Key: key, Message: message, File: origins[key]}
+		if v.File == "" {
+			// a whole unknown section is reported once, at the file of any of its keys
+			for k, origin := range origins {
+				if strings.HasPrefix(k, key+".") {
+					v.File = origin
+					break
+				}
+			}
+		}
+		v.File, v.Line = keyLocation(v.File, key)
+		violations = append(violations, v)
+	})
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Key < violations[j].Key })
+	return violations
+}
+
+// checkSchema validates the config file layer according to Options.SchemaValidation
+func (c *Config) checkSchema(settings map[string]interface{}, origins map[string]string) error {
+	if c.opts.SchemaValidation == SchemaValidationOff {
+		return nil
+	}
+	violations := c.ValidateSettings(settings, origins)
+	if len(violations) == 0 {
+		return nil
+	}
+	if c.opts.SchemaValidation == SchemaValidationError {
+		return &SchemaError{Violations: violations}
+	}
+	for _, v := range violations {
+		logger.Warnf("%s", v)
+	}
+	return nil
+}
+
+// validateSchema reports every value under key not matching s
+//
+//nolint:cyclop // one case per schema keyword
+func validateSchema(s *Schema, key string, value interface{}, report func(key, message string)) {
+	if s == nil || value == nil {
+		return
+	}
+	if !matchesSchemaType(s, value) {
+		report(key, fmt.Sprintf("expected %s, got %T '%v'", schemaTypeName(s), value, value))
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report(key, fmt.Sprintf("'%v' is not one of %v", value, s.Enum))
+		}
+	}
+	if str, ok := value.(string); ok && s.Pattern != "" {
+		if matched, err := regexp.MatchString(s.Pattern, str); err == nil && !matched {
+			report(key, fmt.Sprintf("'%s' does not match the pattern '%s'", str, s.Pattern))
+		}
+	}
+	if n, ok := toFloat(value); ok {
+		if s.Minimum != nil && n < *s.Minimum {
+			report(key, fmt.Sprintf("%v is less than the minimum %v", value, *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			report(key, fmt.Sprintf("%v is greater than the maximum %v", value, *s.Maximum))
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childKey := k
+			if key != "" {
+				childKey = stringutil.ConcatStrings(key, ".", k)
+			}
+			if child, ok := s.Properties[k]; ok {
+				validateSchema(child, childKey, v[k], report)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					report(childKey, "unknown key")
+				}
+			case *Schema:
+				validateSchema(additional, childKey, v[k], report)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			validateSchema(s.Items, fmt.Sprintf("%s[%d]", key, i), item, report)
+		}
+	}
+}
+
+func matchesSchemaType(s *Schema, value interface{}) bool {
+	switch s.Type {
+	case "":
+		return true
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "string":
+		str, ok := value.(string)
+		if ok && s.Format == "duration" {
+			_, err := time.ParseDuration(str)
+			return err == nil
+		}
+		return ok
+	}
+	return true
+}
+
+func schemaTypeName(s *Schema) string {
+	if s.Format != "" {
+		return fmt.Sprintf("%s (%s)", s.Type, s.Format)
+	}
+	return s.Type
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// keyLocation returns the file and the line of the dotted key in origin, which is a file optionally followed by
+// #section for profile sections. The line is only found in YAML and JSON files
+func keyLocation(origin, key string) (string, int) {
+	path, section, _ := strings.Cut(origin, "#")
+	if section != "" {
+		key = stringutil.ConcatStrings(section, ".", key)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return path, 0
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return path, 0
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return path, 0
+	}
+	return path, yamlKeyLine(doc.Content[0], strings.Split(strings.SplitN(key, "[", 2)[0], "."))
+}
+
+// yamlKeyLine returns the line of the key found by walking the path segments, case insensitive like viper
+func yamlKeyLine(node *yaml.Node, segments []string) int {
+	if node.Kind != yaml.MappingNode || len(segments) == 0 {
+		return 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !strings.EqualFold(node.Content[i].Value, segments[0]) {
+			continue
+		}
+		if len(segments) == 1 {
+			return node.Content[i].Line
+		}
+		return yamlKeyLine(node.Content[i+1], segments[1:])
+	}
+	return 0
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+type testSchemaConfig struct {
+	Port    int      `default:"8080" min:"1" max:"65535"`
+	Mode    string   `oneof:"fast slow"`
+	Servers []string `usage:"Upstream servers"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	c := newSourceTestConfig(t, nil)
+	_, deploy, _ := newKeyTestCommands()
+	if err := c.RegisterSchema(deploy, &testSchemaConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	schema := c.JSONSchema()
+
+	tests := []struct {
+		key        string
+		schemaType string
+		check      func(s *Schema) bool
+	}{
+		{defaults.LogLevelKey, "string", func(s *Schema) bool { return s.Pattern != "" }},
+		{defaults.LogFormatKey, "string", func(s *Schema) bool { return len(s.Enum) > 0 }},
+		{defaults.LogFileKey, "string", nil},
+		{defaults.LogFileMaxSizeKey, "", func(s *Schema) bool { return s.Pattern != "" && s.Default == "100MB" }},
+		{defaults.LogFileMaxAgeKey, "string", func(s *Schema) bool { return s.Format == "duration" }},
+		{defaults.LogFileMaxBackupsKey, "integer", func(s *Schema) bool { return s.Default == float64(5) }},
+		{defaults.LogFileCompressKey, "boolean", func(s *Schema) bool { return s.Default == true }},
+		{defaults.LogStderrKey, "boolean", nil},
+		{defaults.VersionKey, "integer", nil},
+		{"deploy.port", "integer", func(s *Schema) bool { return *s.Minimum == 1 && *s.Maximum == 65535 }},
+		{"deploy.mode", "string", func(s *Schema) bool { return len(s.Enum) == 2 }},
+		{"deploy.servers", "array", func(s *Schema) bool { return s.Items.Type == "string" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			node := schema
+			for _, segment := range strings.Split(tt.key, ".") {
+				node = node.Properties[segment]
+				if node == nil {
+					t.Fatalf("Expected the schema to have '%s'", tt.key)
+				}
+			}
+			if node.Type != tt.schemaType {
+				t.Errorf("Expected type '%s', got '%s'", tt.schemaType, node.Type)
+			}
+			if tt.check != nil && !tt.check(node) {
+				t.Errorf("Unexpected schema %+v", node)
+			}
+		})
+	}
+	for _, key := range []string{"config", defaults.ProfileKey} {
+		if _, ok := schema.Properties[key]; ok {
+			t.Errorf("Expected no '%s' in the schema", key)
+		}
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		violations []string
+	}{
+		{
+			name: "every built-in key",
+			content: `version: 1
+log-level: warn,config=debug
+log-format: json
+log-file: {{dir}}/app.log
+log-file-max-size: 10MB
+log-file-max-age: 24h
+log-file-max-backups: 3
+log-file-compress: false
+log-stderr: false
+deploy:
+  port: 443
+`,
+		},
+		{name: "size in bytes", content: "log-file-max-size: 1048576\n"},
+		{
+			name: "invalid values",
+			content: `log-level: loud
+log-file-max-size: big
+log-file-max-age: 1
+deploy:
+  port: 70000
+  unknown: true
+`,
+			violations: []string{
+				"deploy.port", "deploy.unknown", defaults.LogFileMaxAgeKey, defaults.LogFileMaxSizeKey, defaults.LogLevelKey,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, filepath.Join(dir, "app.yaml"), strings.ReplaceAll(tt.content, "{{dir}}", dir))
+			defer func() { _ = log.SetLogFile(log.FileOptions{}, true) }()
+			c := newSourceTestConfig(t, []string{path}, WithSchemaValidation(SchemaValidationError))
+			_, deploy, _ := newKeyTestCommands()
+			if err := c.RegisterSchema(deploy, &testSchemaConfig{}); err != nil {
+				t.Fatal(err)
+			}
+			err := c.InitConfig()
+			if len(tt.violations) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			schemaErr, ok := err.(*SchemaError)
+			if !ok {
+				t.Fatalf("Expected a *SchemaError, got %v", err)
+			}
+			keys := make([]string, 0, len(schemaErr.Violations))
+			for _, v := range schemaErr.Violations {
+				keys = append(keys, v.Key)
+				if v.File != path || v.Line == 0 {
+					t.Errorf("Expected the violation located in '%s', got %s", path, v)
+				}
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.violations, ",") {
+				t.Errorf("Expected violations of %v, got %v", tt.violations, schemaErr.Violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// SecretMask replaces resolved secret values in any effective config output
+const SecretMask = "******"
+
+// DefaultResolverTimeout bounds the time a single reference may take to resolve, unless set with WithResolverTimeout
+const DefaultResolverTimeout = 30 * time.Second
+
+// referencePattern matches ${scheme:reference}
+var referencePattern = regexp.MustCompile(`\$\{([a-zA-Z][a-zA-Z0-9_-]*):([^}]*)\}`)
+
+// Resolver returns the value for ref, the part after the scheme in a ${scheme:ref} config value
+type Resolver func(ctx context.Context, ref string) (string, error)
+
+// DefaultResolvers returns the built-in resolvers enabled by default:
+//   - ${env:NAME} the value of the environment variable NAME, which must be set
+//   - ${file:/run/secrets/x} the content of the file, without the trailing new line
+//
+// References are only resolved in local config files, not in the content of a Source. See also WithCmdResolver
+func DefaultResolvers() map[string]Resolver {
+	return map[string]Resolver{
+		"env":  resolveEnv,
+		"file": resolveFile,
+	}
+}
+
+// WithCmdResolver enables ${cmd:command args} references, replaced by the standard output of the command
+// run by the system shell, without the trailing new line
+func WithCmdResolver() Option {
+	return WithResolver("cmd", resolveCmd)
+}
+
+// WithResolver registers resolver for ${scheme:...} references, replacing any existing one for the same scheme
+func WithResolver(scheme string, resolver Resolver) Option {
+	return func(o *Options) {
+		if o.Resolvers == nil {
+			o.Resolvers = make(map[string]Resolver)
+		}
+		o.Resolvers[scheme] = resolver
+	}
+}
+
+// WithResolverTimeout bounds the time a single reference may take to resolve
+func WithResolverTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ResolverTimeout = timeout
+	}
+}
+
+// WithoutResolver disables the resolver for scheme, leaving its references untouched
+func WithoutResolver(scheme string) Option {
+	return func(o *Options) {
+		delete(o.Resolvers, scheme)
+	}
+}
+
+// resolveLayerReferences resolves the references of layer, see resolveReferences.
+// Layers read from a Source are left untouched: their content must not read local files or run commands
+func (opts *Options) resolveLayerReferences(layer configLayer) (map[string]bool, error) {
+	if layer.untrusted {
+		return nil, nil
+	}
+	secrets, err := opts.resolveReferences(layer.settings)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", layer.source, err)
+	}
+	return secrets, nil
+}
+
+// resolveReferences replaces in place all ${scheme:ref} references found in string values of cfg.
+// It returns the dotted keys, and list items like `key[0]`, holding resolved values so they can be masked
+func (opts *Options) resolveReferences(cfg map[string]interface{}) (map[string]bool, error) {
+	secrets := make(map[string]bool)
+	if len(opts.Resolvers) == 0 {
+		return secrets, nil
+	}
+	var walk func(prefix string, m map[string]interface{}) error
+	walk = func(prefix string, m map[string]interface{}) error {
+		for k, v := range m {
+			key := prefix + k
+			switch value := v.(type) {
+			case map[string]interface{}:
+				if err := walk(stringutil.ConcatStrings(key, "."), value); err != nil {
+					return err
+				}
+			case string:
+				resolved, ok, err := opts.resolveString(value)
+				if err != nil {
+					return fmt.Errorf("cannot resolve '%s': %w", key, err)
+				}
+				if ok {
+					m[k] = resolved
+					secrets[key] = true
+				}
+			case []interface{}:
+				for i, item := range value {
+					s, isString := item.(string)
+					if !isString {
+						continue
+					}
+					resolved, ok, err := opts.resolveString(s)
+					if err != nil {
+						return fmt.Errorf("cannot resolve '%s[%d]': %w", key, i, err)
+					}
+					if ok {
+						value[i] = resolved
+						secrets[fmt.Sprintf("%s[%d]", key, i)] = true
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return secrets, walk("", cfg)
+}
+
+// resolveString replaces every reference in s, returning false when there was none
+func (opts *Options) resolveString(s string) (string, bool, error) {
+	matches := referencePattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, false, nil
+	}
+	var strBuilder strings.Builder
+	last := 0
+	for _, m := range matches {
+		scheme, ref := s[m[2]:m[3]], s[m[4]:m[5]]
+		resolver, ok := opts.Resolvers[scheme]
+		if !ok {
+			return "", false, fmt.Errorf("no resolver registered for '%s'", scheme)
+		}
+		timeout := opts.ResolverTimeout
+		if timeout <= 0 {
+			timeout = DefaultResolverTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		value, err := resolver(ctx, ref)
+		cancel()
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %w", scheme, err)
+		}
+		strBuilder.WriteString(s[last:m[0]])
+		strBuilder.WriteString(value)
+		last = m[1]
+	}
+	strBuilder.WriteString(s[last:])
+	return strBuilder.String(), true, nil
+}
+
+func resolveEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable '%s' is not set", name)
+	}
+	return value, nil
+}
+
+func resolveFile(_ context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func resolveCmd(ctx context.Context, command string) (string, error) {
+	out, err := runShell(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// runShell returns the standard output of command run by the system shell
+func runShell(ctx context.Context, command string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// IsSecret returns true if the value of key was resolved from a reference
+func (c *Config) IsSecret(key string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.secrets[strings.ToLower(key)]
+}
+
+// EffectiveSettings returns all settings like viper.AllSettings, with resolved secrets masked
+func (c *Config) EffectiveSettings() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.effectiveSettings()
+}
+
+// effectiveSettings is EffectiveSettings, with c.mu held
+func (c *Config) effectiveSettings() map[string]interface{} {
+	settings := c.viper.AllSettings()
+	var mask func(prefix string, m map[string]interface{})
+	mask = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			key := prefix + k
+			if sub, ok := v.(map[string]interface{}); ok {
+				mask(stringutil.ConcatStrings(key, "."), sub)
+				continue
+			}
+			if c.IsSecret(key) || c.hasSecretItems(key) {
+				m[k] = SecretMask
+			}
+		}
+	}
+	mask("", settings)
+	return settings
+}
+
+// forgetSecret removes from secrets key and its list items, overridden by a later config layer
+func forgetSecret(secrets map[string]bool, key string) {
+	delete(secrets, key)
+	for k := range secrets {
+		if strings.HasPrefix(k, key+"[") {
+			delete(secrets, k)
+		}
+	}
+}
+
+// hasSecretItems returns true if any item of the list at key was resolved from a reference
+func (c *Config) hasSecretItems(key string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	for k := range c.secrets {
+		if strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := writeTestFile(t, filepath.Join(dir, "secret"), "from-file\n")
+	marker := filepath.Join(dir, "ran")
+	t.Setenv("TEST_SECRET", "from-env")
+
+	tests := []struct {
+		name     string
+		value    string
+		options  []Option
+		expected string
+		err      string
+	}{
+		{name: "env", value: "${env:TEST_SECRET}", expected: "from-env"},
+		{name: "file", value: "${file:" + secretFile + "}", expected: "from-file"},
+		{name: "embedded", value: "user:${env:TEST_SECRET}@host", expected: "user:from-env@host"},
+		{name: "no reference", value: "plain", expected: "plain"},
+		{name: "missing env", value: "${env:TEST_MISSING_SECRET}", err: "is not set"},
+		{name: "cmd disabled", value: "${cmd:echo ran > " + marker + "}", err: "no resolver registered for 'cmd'"},
+		{name: "cmd enabled", value: "${cmd:echo from-cmd}", options: []Option{WithCmdResolver()}, expected: "from-cmd"},
+		{name: "env disabled", value: "${env:TEST_SECRET}", options: []Option{WithoutResolver("env")},
+			err: "no resolver registered for 'env'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "value: '"+tt.value+"'\n")
+			c := newSourceTestConfig(t, []string{path}, tt.options...)
+			err := c.InitConfig()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value := c.Viper().GetString("value"); value != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, value)
+			}
+			if isSecret := c.IsSecret("value"); isSecret != (tt.value != tt.expected) {
+				t.Errorf("Expected IsSecret %v, got %v", tt.value != tt.expected, isSecret)
+			}
+		})
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the cmd reference not to run unless enabled")
+	}
+}
+
+func TestSourceReferencesNotResolved(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	t.Setenv("TEST_SECRET", "from-env")
+	c := newSourceTestConfig(t,
+		[]string{"json+static:x"},
+		WithCmdResolver(),
+		WithSourceOpener("static", func(string) (Source, error) {
+			return staticSource(`{"env": "${env:TEST_SECRET}", "cmd": "${cmd:echo ran > ` + marker + `}"}`), nil
+		}),
+	)
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if value := c.Viper().GetString("env"); value != "${env:TEST_SECRET}" {
+		t.Errorf("Expected the reference to be kept, got %s", value)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the cmd reference of a source not to run")
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	t.Setenv("TEST_PASSWORD", "s3cret")
+	t.Setenv("TEST_TOKEN", "tok")
+	dir := t.TempDir()
+	base := writeTestFile(t, filepath.Join(dir, "base", "app.yaml"), `
+db:
+  password: ${env:TEST_PASSWORD}
+  user: admin
+tokens: [plain, "${env:TEST_TOKEN}"]
+# a plain value equal to a secret is not masked
+hint: s3cret
+api:
+  key: ${env:TEST_TOKEN}
+`)
+	// a plain value in a later layer is not a secret anymore
+	override := writeTestFile(t, filepath.Join(dir, "override", "app.yaml"), "api:\n  key: public\n")
+	c := newSourceTestConfig(t, []string{base, override})
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"db":     map[string]interface{}{"password": SecretMask, "user": "admin"},
+		"tokens": SecretMask,
+		"api":    map[string]interface{}{"key": "public"},
+		"hint":   "s3cret",
+	}
+	settings := c.EffectiveSettings()
+	for _, key := range []string{"db", "tokens", "api", "hint"} {
+		if !reflect.DeepEqual(settings[key], expected[key]) {
+			t.Errorf("Expected %s: %v, got %v", key, expected[key], settings[key])
+		}
+	}
+	if c.IsSecret("api.key") {
+		t.Error("Expected api.key, overridden by a plain value, not to be a secret")
+	}
+}
+
+func TestResolverTimeout(t *testing.T) {
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "password: ${slow:x}\n")
+	slow := func(ctx context.Context, _ string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	c := newSourceTestConfig(t, []string{path},
+		WithStrict(true), WithResolver("slow", slow), WithResolverTimeout(10*time.Millisecond))
+	err := c.InitConfig()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the resolver to time out, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is an immutable copy of the effective config settings, safe to share across goroutines,
+// unlike viper. Keys are dotted and relative to the root command, see PrefixKey
+type Snapshot struct {
+	settings map[string]interface{}
+	// flat holds the leaf values by dotted key
+	flat map[string]interface{}
+	// secrets holds the keys whose values were resolved from references
+	secrets map[string]bool
+}
+
+// Snapshot returns an immutable copy of the current settings of c
+func (c *Config) Snapshot() *Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snapshot()
+}
+
+// snapshot is Snapshot for callers holding c.mu
+func (c *Config) snapshot() *Snapshot {
+	settings, _ := copyValue(c.viper.AllSettings()).(map[string]interface{})
+	s := &Snapshot{
+		settings: settings,
+		flat:     flattenSettings(settings),
+		secrets:  make(map[string]bool),
+	}
+	for k := range s.flat {
+		if c.IsSecret(k) || c.hasSecretItems(k) {
+			s.secrets[k] = true
+		}
+	}
+	return s
+}
+
+// Get returns a copy of the value of the dotted key, a nested map for sections, nil if not set
+func (s *Snapshot) Get(key string) interface{} {
+	return copyValue(settingAt(s.settings, strings.ToLower(key)))
+}
+
+// IsSet returns true if the dotted key, or a section, is set
+func (s *Snapshot) IsSet(key string) bool {
+	return settingAt(s.settings, strings.ToLower(key)) != nil
+}
+
+// Keys returns the sorted dotted keys of the leaf values
+func (s *Snapshot) Keys() []string {
+	keys := make([]string, 0, len(s.flat))
+	for k := range s.flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AllSettings returns a copy of the nested settings
+func (s *Snapshot) AllSettings() map[string]interface{} {
+	settings, _ := copyValue(s.settings).(map[string]interface{})
+	return settings
+}
+
+// GetString returns the value of key as a string, empty if not set or not convertible
+func (s *Snapshot) GetString(key string) string {
+	return SnapshotValue[string](s, key)
+}
+
+// GetStringSlice returns the value of key as a slice of strings, nil if not set or not convertible
+func (s *Snapshot) GetStringSlice(key string) []string {
+	return SnapshotValue[[]string](s, key)
+}
+
+// GetInt returns the value of key as an int, 0 if not set or not convertible
+func (s *Snapshot) GetInt(key string) int {
+	return SnapshotValue[int](s, key)
+}
+
+// GetBool returns the value of key as a bool, false if not set or not convertible
+func (s *Snapshot) GetBool(key string) bool {
+	return SnapshotValue[bool](s, key)
+}
+
+// GetDuration returns the value of key as a time.Duration, 0 if not set or not convertible
+func (s *Snapshot) GetDuration(key string) time.Duration {
+	return SnapshotValue[time.Duration](s, key)
+}
+
+// SnapshotValue returns the value of key in s as a T, the zero value when not set or not convertible.
+// See SnapshotValueE
+func SnapshotValue[T any](s *Snapshot, key string) T {
+	value, _ := SnapshotValueE[T](s, key)
+	return value
+}
+
+// SnapshotValueE returns the value of key in s as a T, converted like GetEWith does
+func SnapshotValueE[T any](s *Snapshot, key string) (T, error) {
+	return convertValue[T](strings.ToLower(key), s.Get(key))
+}
+
+// Diff returns the changes from s to next
+func (s *Snapshot) Diff(next *Snapshot) *Diff {
+	d := &Diff{}
+	for k, v := range next.flat {
+		old, ok := s.flat[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, k)
+		case !reflect.DeepEqual(old, v):
+			d.Changed = append(d.Changed, Change{Key: k, Old: old, New: v, Secret: s.secrets[k] || next.secrets[k]})
+		}
+	}
+	for k := range s.flat {
+		if _, ok := next.flat[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Key < d.Changed[j].Key })
+	return d
+}
+
+// Diff holds the sorted keys added, removed and changed between two snapshots
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed []Change
+}
+
+// Change is a key whose value changed
+type Change struct {
+	Key string
+	Old interface{}
+	New interface{}
+	// Secret is true when either value was resolved from a reference, so it is masked when printed
+	Secret bool
+}
+
+func (c Change) String() string {
+	if c.Secret {
+		return fmt.Sprintf("%s: %s -> %s", c.Key, SecretMask, SecretMask)
+	}
+	return fmt.Sprintf("%s: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// IsEmpty returns true if nothing changed
+func (d *Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Keys returns the sorted keys that were added, removed or changed
+func (d *Diff) Keys() []string {
+	keys := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	keys = append(keys, d.Added...)
+	keys = append(keys, d.Removed...)
+	for _, c := range d.Changed {
+		keys = append(keys, c.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String describes the diff without revealing secret values
+func (d *Diff) String() string {
+	parts := make([]string, 0, 3)
+	if len(d.Added) > 0 {
+		parts = append(parts, "added: "+strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(d.Removed, ", "))
+	}
+	if len(d.Changed) > 0 {
+		changes := make([]string, 0, len(d.Changed))
+		for _, c := range d.Changed {
+			changes = append(changes, c.String())
+		}
+		parts = append(parts, "changed: "+strings.Join(changes, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// copyValue returns a deep copy of the maps and slices of value
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = copyValue(item)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = copyValue(item)
+		}
+		return s
+	case []string:
+		return append([]string(nil), v...)
+	}
+	return value
+}
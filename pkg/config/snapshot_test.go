@@ -0,0 +1,51 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "app.yaml"), "server:\n  port: 8080\n  hosts: [a, b]\ntimeout: 5s\n")
+	c := newSourceTestConfig(t, []string{dir}, WithConfigName("app"))
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := c.Snapshot()
+	if port := before.GetInt("server.port"); port != 8080 {
+		t.Errorf("Expected port 8080, got %d", port)
+	}
+	if timeout := before.GetDuration("timeout"); timeout != 5*time.Second {
+		t.Errorf("Expected timeout 5s, got %s", timeout)
+	}
+	hosts := before.GetStringSlice("server.hosts")
+	hosts[0] = "changed"
+	if host := before.GetStringSlice("server.hosts")[0]; host != "a" {
+		t.Errorf("Expected the snapshot to be immutable, got host '%s'", host)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.Snapshot().GetString("server.port")
+		}()
+	}
+	c.Set(nil, "server.port", "9090")
+	c.Set(nil, "name", "api")
+	wg.Wait()
+
+	diff := before.Diff(c.Snapshot())
+	if len(diff.Added) != 1 || diff.Added[0] != "name" || len(diff.Removed) != 0 ||
+		len(diff.Changed) != 1 || diff.Changed[0].Key != "server.port" {
+		t.Errorf("Unexpected diff: %s", diff)
+	}
+	if empty := c.Snapshot().Diff(c.Snapshot()); !empty.IsEmpty() {
+		t.Errorf("Expected an empty diff, got %s", empty)
+	}
+}
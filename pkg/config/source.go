@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/thedataflows/go-commons/pkg/defaults"
+)
+
+// DefaultSourceTimeout bounds the time a single Source may take to read, unless set with WithSourceTimeout
+const DefaultSourceTimeout = 30 * time.Second
+
+// StdinSourceScheme is the UserConfigPaths entry reading the config from the standard input
+const StdinSourceScheme = "-"
+
+// Source is a config source other than a local file or directory, like the standard input or a URL
+type Source interface {
+	// Read returns the whole content of the source. It must give up when ctx is done
+	Read(ctx context.Context) ([]byte, error)
+	// String returns the name of the source, used in logs and provenance
+	String() string
+}
+
+// SourceOpener returns the Source for ref, the UserConfigPaths entry with its format prefix removed
+type SourceOpener func(ref string) (Source, error)
+
+// DefaultSourceOpeners returns the built-in sources, keyed by scheme:
+//   - `-` the standard input, read once and reused on reloads
+//   - `http://` and `https://` the body of a GET request, which must succeed with a 2xx status
+//
+// See also WithExecSource
+func DefaultSourceOpeners() map[string]SourceOpener {
+	return map[string]SourceOpener{
+		StdinSourceScheme: func(string) (Source, error) { return stdin, nil },
+		"http":            openHTTPSource,
+		"https":           openHTTPSource,
+	}
+}
+
+// WithExecSource enables `exec:command args` sources, reading the standard output of the command
+// run by the system shell
+func WithExecSource() Option {
+	return WithSourceOpener("exec", openExecSource)
+}
+
+// WithSourceOpener registers opener for UserConfigPaths entries starting with `scheme:`,
+// replacing any existing one for the same scheme
+func WithSourceOpener(scheme string, opener SourceOpener) Option {
+	return func(o *Options) {
+		if o.SourceOpeners == nil {
+			o.SourceOpeners = make(map[string]SourceOpener)
+		}
+		o.SourceOpeners[scheme] = opener
+	}
+}
+
+// WithSourceTimeout bounds the time a single Source may take to read
+func WithSourceTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.SourceTimeout = timeout
+	}
+}
+
+// openSource returns the Source and its format for p, or a nil Source if p is a local path.
+//
+// The format may be given explicitly with a `<format>+` prefix, for example `json+exec:vault read -format=json x`
+// or `yaml+-`. Otherwise it is ConfigType, or the extension of the URL path, or yaml.
+func (opts *Options) openSource(p string) (Source, string, error) {
+	format := ""
+	ref := p
+	if i := strings.Index(p, "+"); i > 0 && containsString(viper.SupportedExts, p[:i]) {
+		format, ref = p[:i], p[i+1:]
+	}
+	scheme := ref
+	if i := strings.Index(ref, ":"); i > 0 {
+		scheme = ref[:i]
+	}
+	opener, ok := opts.SourceOpeners[scheme]
+	// a single letter scheme is a windows drive
+	if !ok || (scheme != StdinSourceScheme && len(scheme) < 2) {
+		return nil, "", nil
+	}
+	source, err := opener(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid config source '%s': %w", p, err)
+	}
+	if format == "" {
+		format = opts.ConfigType
+	}
+	if format == "" {
+		if u, err := url.Parse(ref); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			format = strings.TrimPrefix(path.Ext(u.Path), ".")
+		}
+	}
+	if !containsString(viper.SupportedExts, format) {
+		format = "yaml"
+	}
+	return source, format, nil
+}
+
+// isSource returns true if p is read by a Source rather than being a local path
+func (opts *Options) isSource(p string) bool {
+	source, _, err := opts.openSource(p)
+	return source != nil || err != nil
+}
+
+// readSourceLayer reads source as format within SourceTimeout
+func (opts *Options) readSourceLayer(source Source, format string) (configLayer, error) {
+	timeout := opts.SourceTimeout
+	if timeout <= 0 {
+		timeout = DefaultSourceTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	content, err := source.Read(ctx)
+	if err != nil {
+		return configLayer{}, fmt.Errorf("failed to read config source '%s': %w", source, err)
+	}
+	v := newViper()
+	v.SetConfigType(format)
+	if err = v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return configLayer{}, newFileError(source.String(), content, err)
+	}
+	settings := v.AllSettings()
+	if err = opts.migrate(source.String(), settings); err != nil {
+		return configLayer{}, err
+	}
+	if _, ok := settings[defaults.IncludeKey]; ok {
+		logger.Warnf("'%s' is not supported in config source '%s', ignoring it", defaults.IncludeKey, source)
+		delete(settings, defaults.IncludeKey)
+	}
+	return configLayer{source: source.String(), settings: settings, untrusted: true}, nil
+}
+
+// stdinSource reads the standard input once per process, so reloads and all Config instances see the same content.
+// The read goes on in the background after a Read gives up, so a later Read can still get the content
+type stdinSource struct {
+	in      io.Reader
+	start   sync.Once
+	done    chan struct{}
+	content []byte
+	err     error
+}
+
+var stdin = newStdinSource(os.Stdin)
+
+func newStdinSource(in io.Reader) *stdinSource {
+	return &stdinSource{in: in, done: make(chan struct{})}
+}
+
+func (s *stdinSource) Read(ctx context.Context) ([]byte, error) {
+	s.start.Do(func() {
+		go func() {
+			s.content, s.err = io.ReadAll(s.in)
+			close(s.done)
+		}()
+	})
+	select {
+	case <-s.done:
+		return s.content, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *stdinSource) String() string {
+	return "stdin"
+}
+
+// httpSource is a config served over http(s)
+type httpSource struct {
+	url string
+}
+
+func openHTTPSource(ref string) (Source, error) {
+	if _, err := url.ParseRequestURI(ref); err != nil {
+		return nil, err
+	}
+	return &httpSource{url: ref}, nil
+}
+
+func (s *httpSource) Read(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status '%s'", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *httpSource) String() string {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return s.url
+	}
+	// do not leak credentials in logs
+	return u.Redacted()
+}
+
+// execSource is the output of a command
+type execSource struct {
+	command string
+}
+
+func openExecSource(ref string) (Source, error) {
+	command := strings.TrimSpace(strings.TrimPrefix(ref, "exec:"))
+	if command == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+	return &execSource{command: command}, nil
+}
+
+func (s *execSource) Read(ctx context.Context) ([]byte, error) {
+	return runShell(ctx, s.command)
+}
+
+func (s *execSource) String() string {
+	return "exec:" + s.command
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSourceTestConfig(t *testing.T, paths []string, options ...Option) *Config {
+	t.Helper()
+	options = append(options, WithEnvPrefix("TEST"), WithUserConfigPaths(paths))
+	opts, err := NewOptions(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewConfig(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.json":
+			_, _ = w.Write([]byte(`{"server": {"port": 9090}}`))
+		case "/config":
+			_, _ = w.Write([]byte("server:\n  host: remote\n  token: ${env:TEST_SECRET}\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_SECRET", "local")
+	c := newSourceTestConfig(t, []string{server.URL + "/app.json", "yaml+" + server.URL + "/config"})
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// remote content does not get to read local values
+	if token := c.Viper().GetString("server.token"); token != "${env:TEST_SECRET}" {
+		t.Errorf("Expected the reference to be kept, got %s", token)
+	}
+	if port := c.Viper().GetInt("server.port"); port != 9090 {
+		t.Errorf("Expected port 9090, got %d", port)
+	}
+	if host := c.Viper().GetString("server.host"); host != "remote" {
+		t.Errorf("Expected host remote, got %s", host)
+	}
+	if origin := c.ExplainKey("server.host"); origin.Detail != server.URL+"/config" {
+		t.Errorf("Expected origin %s/config, got %s", server.URL, origin.Detail)
+	}
+
+	c = newSourceTestConfig(t, []string{server.URL + "/missing.yaml"})
+	if err := c.InitConfig(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error, got %v", err)
+	}
+}
+
+func TestHTTPSourceTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := newSourceTestConfig(t, []string{server.URL}, WithSourceTimeout(50*time.Millisecond))
+	if err := c.InitConfig(); err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+type staticSource string
+
+func (s staticSource) Read(context.Context) ([]byte, error) {
+	return []byte(s), nil
+}
+
+func (s staticSource) String() string {
+	return "static"
+}
+
+func TestCustomSource(t *testing.T) {
+	c := newSourceTestConfig(t,
+		[]string{"json+static:x", "exec:echo 'name: from-exec'"},
+		WithSourceOpener("static", func(ref string) (Source, error) {
+			return staticSource(`{"name": "static", "tags": ["a"]}`), nil
+		}),
+		WithExecSource(),
+	)
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if name := c.Viper().GetString("name"); name != "from-exec" {
+		t.Errorf("Expected name from-exec, got %s", name)
+	}
+	if tags := c.Viper().GetStringSlice("tags"); len(tags) != 1 || tags[0] != "a" {
+		t.Errorf("Expected tags [a], got %v", tags)
+	}
+}
+
+func TestExecSourceDisabled(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	c := newSourceTestConfig(t, []string{"exec:echo ran > " + marker})
+	// without WithExecSource, the entry is a local path that does not exist
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the exec source not to run")
+	}
+}
+
+func TestStdinSource(t *testing.T) {
+	r, w := io.Pipe()
+	source := newStdinSource(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := source.Read(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a timeout while stdin is open, got %v", err)
+	}
+
+	// the content written after a timeout is still read, once, for every later Read
+	go func() {
+		_, _ = w.Write([]byte("name: piped\n"))
+		_ = w.Close()
+	}()
+	for i := 0; i < 2; i++ {
+		content, err := source.Read(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "name: piped\n" {
+			t.Errorf("Expected the piped content, got '%s'", content)
+		}
+	}
+}
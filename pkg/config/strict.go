@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Markers prefixing UserConfigPaths entries
+const (
+	// OptionalPathMarker makes a path never fail loading, even in strict mode, like `?/etc/app`
+	OptionalPathMarker = "?"
+	// RequiredPathMarker makes a path fail loading when missing or broken, even when not strict, like `!./app.yaml`.
+	// A required directory must contain a config file
+	RequiredPathMarker = "!"
+)
+
+// WithStrict makes InitConfig fail when a config path is not accessible or a config file cannot be read or parsed,
+// instead of logging a warning. See OptionalPathMarker and RequiredPathMarker to decide per path
+func WithStrict(strict bool) Option {
+	return func(o *Options) {
+		o.Strict = strict
+	}
+}
+
+// FileError reports a config file, or source, that could not be read or parsed. Line is 0 when unknown
+type FileError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// LoadError aggregates all the FileErrors found while loading the config
+type LoadError struct {
+	Errors []*FileError
+}
+
+func (e *LoadError) Error() string {
+	var strBuilder strings.Builder
+	strBuilder.WriteString("failed to load config:")
+	for _, fe := range e.Errors {
+		strBuilder.WriteString("\n  ")
+		strBuilder.WriteString(fe.Error())
+	}
+	return strBuilder.String()
+}
+
+// configPath is a UserConfigPaths entry without its marker
+type configPath struct {
+	path     string
+	optional bool
+	required bool
+}
+
+// configPaths returns UserConfigPaths with their markers parsed
+func (opts *Options) configPaths() []configPath {
+	paths := make([]configPath, 0, len(opts.UserConfigPaths))
+	for _, p := range opts.UserConfigPaths {
+		switch {
+		case strings.HasPrefix(p, OptionalPathMarker):
+			paths = append(paths, configPath{path: strings.TrimPrefix(p, OptionalPathMarker), optional: true})
+		case strings.HasPrefix(p, RequiredPathMarker):
+			paths = append(paths, configPath{path: strings.TrimPrefix(p, RequiredPathMarker), required: true})
+		default:
+			paths = append(paths, configPath{path: p})
+		}
+	}
+	return paths
+}
+
+// loadErrors collects the problems found while loading the config paths
+type loadErrors struct {
+	strict bool
+	errors []*FileError
+}
+
+// add records err for p if it must fail loading, logs it otherwise. Missing optional paths are only debug logged
+func (l *loadErrors) add(p configPath, err *FileError, missing bool) {
+	switch {
+	case p.required || (l.strict && !p.optional):
+		l.errors = append(l.errors, err)
+	case p.optional && missing:
+		logger.Debugf("%s", err)
+	default:
+		logger.Warnf("%s", err)
+	}
+}
+
+// err returns a *LoadError if any problem was recorded
+func (l *loadErrors) err() error {
+	if len(l.errors) == 0 {
+		return nil
+	}
+	return &LoadError{Errors: l.errors}
+}
+
+// yamlLinePattern matches the line yaml, hcl and ini parsers put in their messages
+var yamlLinePattern = regexp.MustCompile(`line (\d+)`)
+
+// newFileError returns a FileError for the failure to parse content, with the line found in the parser error
+func newFileError(file string, content []byte, err error) *FileError {
+	fe := &FileError{File: file, Err: err}
+	var parseErr viper.ConfigParseError
+	if errors.As(err, &parseErr) {
+		// drop the "While parsing config:" prefix
+		fe.Err = parseErr.Unwrap()
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var positionErr interface{ Position() (int, int) }
+	switch {
+	case errors.As(err, &syntaxErr):
+		fe.Line = offsetLine(content, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		fe.Line = offsetLine(content, typeErr.Offset)
+	case errors.As(err, &positionErr):
+		fe.Line, _ = positionErr.Position()
+	default:
+		if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			fe.Line, _ = strconv.Atoi(m[1])
+		}
+	}
+	return fe
+}
+
+// offsetLine returns the 1 based line of the byte offset in content
+func offsetLine(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// asFileError returns err as a *FileError, for file if it is not one already
+func asFileError(file string, err error) *FileError {
+	var fe *FileError
+	if errors.As(err, &fe) {
+		return fe
+	}
+	return &FileError{File: file, Err: err}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStrict(t *testing.T) {
+	dir := t.TempDir()
+	brokenYAML := writeTestFile(t, filepath.Join(dir, "yaml", "app.yaml"), "server:\n  port: 1\n bad\n")
+	brokenJSON := writeTestFile(t, filepath.Join(dir, "json", "app.json"), "{\n  \"port\": 1,\n}\n")
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name     string
+		strict   bool
+		paths    []string
+		expected []FileError
+	}{
+		{"lenient", false, []string{brokenYAML, missing}, nil},
+		{"strict", true, []string{brokenYAML, brokenJSON, missing}, []FileError{
+			{File: brokenYAML, Line: 2},
+			{File: brokenJSON, Line: 3},
+			{File: missing},
+		}},
+		{"strict optional", true, []string{"?" + brokenYAML, "?" + missing}, nil},
+		{"lenient required", false, []string{brokenYAML, "!" + brokenJSON, "!" + dir}, []FileError{
+			{File: brokenJSON, Line: 3},
+			{File: dir},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newSourceTestConfig(t, tt.paths, WithConfigName("app"), WithStrict(tt.strict))
+			err := c.InitConfig()
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var loadErr *LoadError
+			if !errors.As(err, &loadErr) {
+				t.Fatalf("Expected a *LoadError, got %v", err)
+			}
+			if len(loadErr.Errors) != len(tt.expected) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.expected), loadErr)
+			}
+			for i, expected := range tt.expected {
+				fe := loadErr.Errors[i]
+				if fe.File != expected.File || fe.Line != expected.Line || fe.Err == nil {
+					t.Errorf("Expected %s:%d, got %v", expected.File, expected.Line, fe)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/thedataflows/go-commons/pkg/log"
+)
+
+// CheckRequiredFlags exits with error when one ore more required flags are not set
+func (c *Config) CheckRequiredFlags(cmd *cobra.Command, requiredFlags []string) error {
+	neededFlags := make([]string, 0, len(requiredFlags))
+	for _, f := range requiredFlags {
+		if !c.IsSet(cmd, f) {
+			neededFlags = append(neededFlags, f)
+		}
+	}
+
+	if len(neededFlags) > 0 {
+		errorMessage := "required flags are not set:"
+		for _, f := range neededFlags {
+			errorMessage = fmt.Sprintf("%s\n  --%s", errorMessage, f)
+		}
+		errorMessage = fmt.Sprintf("%s\n", errorMessage)
+		return fmt.Errorf(errorMessage)
+	}
+
+	return nil
+}
+
+// AppendStringArgsf appends viper value to existing args slice with optional formatted output with key and value
+func (c *Config) AppendStringArgsf(format string, cmd *cobra.Command, args []string, key string) []string {
+	val := c.GetString(cmd, key)
+	if val != "" {
+		args = append(args, fmt.Sprintf(format, key, val))
+	}
+	return args
+}
+
+// AppendStringArgs appends viper value to existing args slice
+func (c *Config) AppendStringArgs(cmd *cobra.Command, args []string, key string) []string {
+	return c.AppendStringArgsf("", cmd, args, key)
+}
+
+// AppendSplitArgs appends viper value to existing args slice after splitting them by splitPattern (default regex whitespace)
+func (c *Config) AppendStringSplitArgs(cmd *cobra.Command, args []string, key string, splitPattern string) []string {
+	if splitPattern == "" {
+		splitPattern = `\s+`
+	}
+	val := c.GetString(cmd, key)
+	if val != "" {
+		args = append(args, regexp.MustCompile(splitPattern).Split(val, -1)...)
+	}
+	return args
+}
+
+// BindPFlag is a convenience wrapper over viper.BindPFlag for local flags
+func (c *Config) BindPFlag(cmd *cobra.Command, name string) {
+	_ = c.bindPFlag(c.prefixKey(cmd, name), cmd.Flags().Lookup(name))
+}
+
+// BindPFlagSet is a convenience wrapper over viper.BindPFlag for local FlagSet
+//
+// if flags is nil, the cmd.Flags() will be used
+func (c *Config) BindPFlagSet(cmd *cobra.Command, flags *pflag.FlagSet) {
+	if flags == nil {
+		flags = cmd.Flags()
+	}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		_ = c.bindPFlag(c.prefixKey(cmd, flag.Name), flag)
+	})
+}
+
+// BindPersistentPFlag is a convenience wrapper over viper.BindPFlag for persistent flags
+func (c *Config) BindPersistentPFlag(cmd *cobra.Command, name string) {
+	_ = c.bindPFlag(c.prefixKey(cmd, name), cmd.PersistentFlags().Lookup(name))
+}
+
+// bindPFlag binds flag to key and remembers it, so the source of the key can be explained
+func (c *Config) bindPFlag(key string, flag *pflag.Flag) error {
+	if err := c.viper.BindPFlag(key, flag); err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.bindings == nil {
+		c.bindings = make(map[string]*pflag.Flag)
+	}
+	c.bindings[strings.ToLower(key)] = flag
+	return nil
+}
+
+// GetString returns the value associated with the key as a string.
+func (c *Config) GetString(cmd *cobra.Command, key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetString(c.prefixKey(cmd, key))
+}
+
+// GetStringSlice returns the value associated with the key as a slice of strings.
+func (c *Config) GetStringSlice(cmd *cobra.Command, key string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetStringSlice(c.prefixKey(cmd, key))
+}
+
+// GetStringMap returns the value associated with the key as a map of interfaces.
+func (c *Config) GetStringMap(cmd *cobra.Command, key string) map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetStringMap(c.prefixKey(cmd, key))
+}
+
+// GetStringMapString returns the value associated with the key as a map of strings.
+func (c *Config) GetStringMapString(cmd *cobra.Command, key string) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetStringMapString(c.prefixKey(cmd, key))
+}
+
+// GetStringMapStringSlice returns the value associated with the key as a map to a slice of strings.
+func (c *Config) GetStringMapStringSlice(cmd *cobra.Command, key string) map[string][]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetStringMapStringSlice(c.prefixKey(cmd, key))
+}
+
+// GetInt returns the value associated with the key as an integer.
+func (c *Config) GetInt(cmd *cobra.Command, key string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetInt(c.prefixKey(cmd, key))
+}
+
+// GetFloat64 returns the value associated with the key as a float64.
+func (c *Config) GetFloat64(cmd *cobra.Command, key string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetFloat64(c.prefixKey(cmd, key))
+}
+
+// GetTime returns the value associated with the key as time.
+func (c *Config) GetTime(cmd *cobra.Command, key string) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetTime(c.prefixKey(cmd, key))
+}
+
+// GetDuration returns the value associated with the key as a duration.
+func (c *Config) GetDuration(cmd *cobra.Command, key string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetDuration(c.prefixKey(cmd, key))
+}
+
+// GetBool returns the value associated with the key as a boolean.
+func (c *Config) GetBool(cmd *cobra.Command, key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetBool(c.prefixKey(cmd, key))
+}
+
+// GetSizeInBytes returns the size of the value associated with the given key
+func (c *Config) GetSizeInBytes(cmd *cobra.Command, key string) uint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.GetSizeInBytes(c.prefixKey(cmd, key))
+}
+
+// IsSet returns true if a key is set. Case insensitive for keys.
+func (c *Config) IsSet(cmd *cobra.Command, key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.viper.IsSet(c.prefixKey(cmd, key))
+}
+
+// Set sets an override value for specified key
+func (c *Config) Set(cmd *cobra.Command, key, value string) {
+	key = c.prefixKey(cmd, key)
+	c.mu.Lock()
+	if logger.GetLogger().GetLevel() > log.DebugLevel {
+		c.viper.Set(key, value)
+		c.mu.Unlock()
+	} else {
+		before := c.snapshot()
+		c.viper.Set(key, value)
+		diff := before.Diff(c.snapshot())
+		c.mu.Unlock()
+		if !diff.IsEmpty() {
+			logger.Debugf("config overridden, %s", diff)
+		}
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.overrides == nil {
+		c.overrides = make(map[string]bool)
+	}
+	c.overrides[strings.ToLower(key)] = true
+}
+
+// CheckRequiredFlags exits with error when one ore more required flags are not set
+func CheckRequiredFlags(cmd *cobra.Command, requiredFlags []string) error {
+	return Default().CheckRequiredFlags(cmd, requiredFlags)
+}
+
+// AppendStringArgsf appends viper value to existing args slice with optional formatted output with key and value
+func AppendStringArgsf(format string, cmd *cobra.Command, args []string, key string) []string {
+	return Default().AppendStringArgsf(format, cmd, args, key)
+}
+
+// AppendStringArgs appends viper value to existing args slice
+func AppendStringArgs(cmd *cobra.Command, args []string, key string) []string {
+	return Default().AppendStringArgs(cmd, args, key)
+}
+
+// AppendSplitArgs appends viper value to existing args slice after splitting them by splitPattern (default regex whitespace)
+func AppendStringSplitArgs(cmd *cobra.Command, args []string, key string, splitPattern string) []string {
+	return Default().AppendStringSplitArgs(cmd, args, key, splitPattern)
+}
+
+// ViperBindPFlag is a convenience wrapper over viper.BindPFlag for local flags
+func ViperBindPFlag(cmd *cobra.Command, name string) {
+	Default().BindPFlag(cmd, name)
+}
+
+// ViperBindPFlagSet is a convenience wrapper over viper.BindPFlag for local FlagSet
+//
+// if flags is nil, the cmd.Flags() will be used
+func ViperBindPFlagSet(cmd *cobra.Command, flags *pflag.FlagSet) {
+	Default().BindPFlagSet(cmd, flags)
+}
+
+// ViperBindPersistentPFlag is a convenience wrapper over viper.BindPFlag for persistent flags
+func ViperBindPersistentPFlag(cmd *cobra.Command, name string) {
+	Default().BindPersistentPFlag(cmd, name)
+}
+
+// ViperGetString is a convenience wrapper that returns the value associated with the key as a string.
+//
+// Deprecated: use Get[string] or GetE[string] instead.
+func ViperGetString(cmd *cobra.Command, key string) string {
+	return Default().GetString(cmd, key)
+}
+
+// ViperGetStringSlice is a convenience wrapper that returns the value associated with the key as a slice of strings.
+//
+// Deprecated: use Get[[]string] or GetE[[]string] instead.
+func ViperGetStringSlice(cmd *cobra.Command, key string) []string {
+	return Default().GetStringSlice(cmd, key)
+}
+
+// ViperGetStringMap is a convenience wrapper that returns the value associated with the key as a map of interfaces.
+//
+// Deprecated: use Get[map[string]interface{}] or GetE[map[string]interface{}] instead.
+func ViperGetStringMap(cmd *cobra.Command, key string) map[string]interface{} {
+	return Default().GetStringMap(cmd, key)
+}
+
+// ViperGetStringMapString is a convenience wrapper that returns the value associated with the key as a map of strings.
+//
+// Deprecated: use Get[map[string]string] or GetE[map[string]string] instead.
+func ViperGetStringMapString(cmd *cobra.Command, key string) map[string]string {
+	return Default().GetStringMapString(cmd, key)
+}
+
+// ViperGetStringMapStringSlice is a convenience wrapper that returns the value associated with the key as a map to a slice of strings.
+//
+// Deprecated: use Get[map[string][]string] or GetE[map[string][]string] instead.
+func ViperGetStringMapStringSlice(cmd *cobra.Command, key string) map[string][]string {
+	return Default().GetStringMapStringSlice(cmd, key)
+}
+
+// ViperGetInt is a convenience wrapper that returns the value associated with the key as an integer.
+//
+// Deprecated: use Get[int] or GetE[int] instead.
+func ViperGetInt(cmd *cobra.Command, key string) int {
+	return Default().GetInt(cmd, key)
+}
+
+// ViperGetFloat64 is a convenience wrapper that returns the value associated with the key as a float64.
+//
+// Deprecated: use Get[float64] or GetE[float64] instead.
+func ViperGetFloat64(cmd *cobra.Command, key string) float64 {
+	return Default().GetFloat64(cmd, key)
+}
+
+// ViperGetTime is a convenience wrapper that returns the value associated with the key as time.
+//
+// Deprecated: use Get[time.Time] or GetE[time.Time] instead.
+func ViperGetTime(cmd *cobra.Command, key string) time.Time {
+	return Default().GetTime(cmd, key)
+}
+
+// ViperGetDuration is a convenience wrapper that returns the value associated with the key as a duration.
+//
+// Deprecated: use Get[time.Duration] or GetE[time.Duration] instead.
+func ViperGetDuration(cmd *cobra.Command, key string) time.Duration {
+	return Default().GetDuration(cmd, key)
+}
+
+// ViperGetBool is a convenience wrapper that returns the value associated with the key as a boolean.
+//
+// Deprecated: use Get[bool] or GetE[bool] instead.
+func ViperGetBool(cmd *cobra.Command, key string) bool {
+	return Default().GetBool(cmd, key)
+}
+
+// ViperGetSizeInBytes is a convenience wrapper that returns the size of the value associated with the given key
+func ViperGetSizeInBytes(cmd *cobra.Command, key string) uint {
+	return Default().GetSizeInBytes(cmd, key)
+}
+
+// ViperIsSet is a convenience wrapper returning true if a key is set. Case insensitive for keys.
+func ViperIsSet(cmd *cobra.Command, key string) bool {
+	return Default().IsSet(cmd, key)
+}
+
+// ViperSet is a convenience wrapper setting an override value for specified key
+func ViperSet(cmd *cobra.Command, key, value string) {
+	Default().Set(cmd, key, value)
+}
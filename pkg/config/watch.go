@@ -0,0 +1,211 @@
+package config
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/thedataflows/go-commons/pkg/file"
+	"github.com/thedataflows/go-commons/pkg/lang"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+)
+
+// reloadDelay debounces bursts of file events, as most editors write a file in several steps
+const reloadDelay = 100 * time.Millisecond
+
+// ChangeFunc is called after a config reload with the sorted list of keys whose values changed
+type ChangeFunc func(changedKeys []string)
+
+// OnChange registers fn to be called after every config reload that changed at least one key
+func (opts *Options) OnChange(fn ChangeFunc) {
+	opts.mu.Lock()
+	defer opts.mu.Unlock()
+	opts.onChange = append(opts.onChange, fn)
+}
+
+// OnChange registers fn to be called after every config reload that changed at least one key
+func (c *Config) OnChange(fn ChangeFunc) {
+	c.opts.OnChange(fn)
+}
+
+// StopWatching stops watching config files for changes. It is safe to call when not watching
+func (c *Config) StopWatching() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.watcher == nil {
+		return nil
+	}
+	err := c.watcher.Close()
+	c.watcher = nil
+	return err
+}
+
+// watchConfig watches the directories of all UserConfigPaths, so config files created later are picked up as well
+func (c *Config) watchConfig() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range c.opts.watchedDirs() {
+		if err := watcher.Add(dir); err != nil {
+			logger.Warnf("cannot watch '%s' for config changes: %s", dir, err)
+			continue
+		}
+		logger.Debugf("watching '%s' for config changes", dir)
+	}
+
+	c.mu.Lock()
+	if c.watcher != nil {
+		_ = c.watcher.Close()
+	}
+	c.watcher = watcher
+	c.mu.Unlock()
+
+	go c.watchLoop(watcher)
+	return nil
+}
+
+func (c *Config) watchLoop(watcher *fsnotify.Watcher) {
+	var timer *time.Timer
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) || !c.opts.isConfigFile(event.Name) {
+				continue
+			}
+			logger.Tracef("config file event: %s", event)
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, c.reloadConfig)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("config watcher error: %s", err)
+		}
+	}
+}
+
+// reloadConfig merges again all config files, reapplies logging settings and notifies the subscribers
+func (c *Config) reloadConfig() {
+	diff, err := c.reload()
+	if err != nil {
+		logger.Errorf("failed to reload config: %s", err)
+		return
+	}
+	if diff.IsEmpty() {
+		logger.Debugf("config reloaded without changes")
+		return
+	}
+	logger.Infof("config reloaded, %s", diff)
+	changed := diff.Keys()
+
+	c.opts.mu.Lock()
+	callbacks := append([]ChangeFunc(nil), c.opts.onChange...)
+	c.opts.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(changed)
+	}
+}
+
+// reload replaces the config file layer and returns what changed
+func (c *Config) reload() (*Diff, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	before := c.snapshot()
+	cfg, err := c.loadConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err = replaceConfig(c.viper, cfg); err != nil {
+		return nil, err
+	}
+	if err = c.setLogging(); err != nil {
+		logger.Errorf("failed to set logging from reloaded config: %s", err)
+	}
+	return before.Diff(c.snapshot()), nil
+}
+
+// watchedDirs returns the unique directories to watch for UserConfigPaths, including existing drop-in directories.
+// Files included from elsewhere and sources are not watched
+func (opts *Options) watchedDirs() []string {
+	dirs := make([]string, 0, len(opts.UserConfigPaths))
+	for _, cp := range opts.configPaths() {
+		p := cp.path
+		if opts.isSource(p) {
+			continue
+		}
+		if dropInDir := opts.dropInDir(p); file.IsDirectory(dropInDir) {
+			dirs = append(dirs, absPath(dropInDir))
+		}
+		if !file.IsDirectory(p) {
+			p = filepath.Dir(p)
+		}
+		if file.IsDirectory(p) {
+			dirs = append(dirs, absPath(p))
+		}
+	}
+	return lang.UniqueSliceElements(dirs)
+}
+
+// isConfigFile returns true if name is one of UserConfigPaths or a ConfigName file inside one of them,
+// including the files of the active profile and the drop-in files
+func (opts *Options) isConfigFile(name string) bool {
+	name = absPath(name)
+	dir, base := filepath.Split(name)
+	dir = filepath.Clean(dir)
+	profile := opts.ActiveProfile()
+	for _, cp := range opts.configPaths() {
+		if opts.isSource(cp.path) {
+			continue
+		}
+		p := absPath(cp.path)
+		if dir == absPath(opts.dropInDir(p)) && opts.hasConfigExt(base) {
+			return true
+		}
+		if p == name ||
+			(profile != "" && name == stringutil.ConcatStrings(file.TrimExtension(p), ".", profile, filepath.Ext(p))) {
+			return true
+		}
+		if p != dir {
+			continue
+		}
+		for _, ext := range opts.configExts() {
+			if base == stringutil.ConcatStrings(opts.ConfigName, ".", ext) ||
+				(profile != "" && base == stringutil.ConcatStrings(opts.ConfigName, ".", profile, ".", ext)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func absPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	return abs
+}
+
+// flattenSettings returns a map of dotted keys to leaf values
+func flattenSettings(settings map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{}, len(settings))
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+				walk(stringutil.ConcatStrings(prefix, k, "."), sub)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", settings)
+	return flat
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// writes are applied in a burst, relative to the directory of the config file
+		writes   map[string]string
+		expected []string
+	}{
+		{name: "burst of writes", writes: map[string]string{"app.yaml": "a: 3\nb: y\n"}, expected: []string{"a", "b"}},
+		{name: "same content", writes: map[string]string{"app.yaml": "a: 1\nb: x\n"}},
+		{name: "other file", writes: map[string]string{"other.yaml": "a: 2\n"}},
+		{name: "new key", writes: map[string]string{"app.yaml": "a: 1\nb: x\nc: z\n"}, expected: []string{"c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeTestFile(t, filepath.Join(dir, "app.yaml"), "a: 1\nb: x\n")
+			changes := make(chan []string, 10)
+			c := newSourceTestConfig(t, []string{path},
+				WithWatchConfig(true),
+				WithOnChange(func(changedKeys []string) { changes <- changedKeys }),
+			)
+			if err := c.InitConfig(); err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = c.StopWatching() }()
+
+			// an intermediate write, superseded by the burst, must not be reported on its own
+			writeTestFile(t, path, "a: 2\nb: x\n")
+			for name, content := range tt.writes {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+
+			var got [][]string
+			timeout := time.After(5 * reloadDelay)
+		wait:
+			for {
+				select {
+				case keys := <-changes:
+					got = append(got, keys)
+				case <-timeout:
+					break wait
+				}
+			}
+			if tt.expected == nil {
+				// the intermediate write may be debounced with the burst or not, depending on timing
+				for _, keys := range got {
+					if strings.Join(keys, ",") != "a" {
+						t.Errorf("Expected only 'a' reported changed, got %v", keys)
+					}
+				}
+				return
+			}
+			if len(got) != 1 || strings.Join(got[0], ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected a single change of %v, got %v", tt.expected, got)
+			}
+			if value := c.Viper().GetString(tt.expected[0]); value == "" {
+				t.Errorf("Expected '%s' to be reloaded", tt.expected[0])
+			}
+		})
+	}
+}
+
+func TestStopWatching(t *testing.T) {
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "a: 1\n")
+	changes := make(chan []string, 1)
+	c := newSourceTestConfig(t, []string{path}, WithWatchConfig(true), WithOnChange(func(keys []string) {
+		changes <- keys
+	}))
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StopWatching(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("a: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case keys := <-changes:
+		t.Errorf("Expected no reload after StopWatching, got %v", keys)
+	case <-time.After(3 * reloadDelay):
+	}
+	if err := c.StopWatching(); err != nil {
+		t.Errorf("Expected StopWatching to be safe to call twice, got %v", err)
+	}
+}
+
+// TestReloadWhileReading is meant for -race: the config is reloaded, like by the watcher, while other goroutines read
+func TestReloadWhileReading(t *testing.T) {
+	path := writeTestFile(t, filepath.Join(t.TempDir(), "app.yaml"), "a: 0\n")
+	c := newSourceTestConfig(t, []string{path})
+	if err := c.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, read := range []func(){
+		func() { _ = c.GetString(nil, "a") },
+		func() { _, _ = GetEWith[int](c, nil, "a") },
+		func() { _ = c.EffectiveSettings() },
+		func() { _ = c.Snapshot() },
+		func() { _ = c.Explain() },
+		func() { _ = c.Keys() },
+	} {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					read()
+				}
+			}
+		}(read)
+	}
+	for i := 1; i <= 20; i++ {
+		writeTestFile(t, path, fmt.Sprintf("a: %d\n", i))
+		if _, err := c.reload(); err != nil {
+			t.Error(err)
+		}
+		c.Set(nil, "b", strconv.Itoa(i))
+	}
+	close(stop)
+	wg.Wait()
+	if value := c.GetString(nil, "a"); value != "20" {
+		t.Errorf("Expected the last reloaded value, got '%s'", value)
+	}
+}
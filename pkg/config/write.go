@@ -0,0 +1,213 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/defaults"
+	"github.com/thedataflows/go-commons/pkg/file"
+	"github.com/thedataflows/go-commons/pkg/stringutil"
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfigFile returns the ConfigName file in AppHome. An existing file with any supported extension is preferred,
+// otherwise the path of a new file with ConfigType, or yaml, is returned
+func (opts *Options) UserConfigFile() (string, error) {
+	appHome := opts.AppHome
+	if appHome == "" {
+		var err error
+		appHome, err = file.AppHome("")
+		if err != nil {
+			return "", err
+		}
+	}
+	if configFile := opts.findConfigFile(appHome); configFile != "" && configFile != appHome {
+		return configFile, nil
+	}
+	configType := opts.ConfigType
+	if configType == "" {
+		configType = "yaml"
+	}
+	return filepath.Join(appHome, stringutil.ConcatStrings(opts.ConfigName, ".", configType)), nil
+}
+
+// WriteUserConfig sets values, by dotted key, in the user config file and reloads the config.
+//
+// The other keys of the file are kept as they are, references included, and the file keeps its format.
+// In yaml files, the comments, order and case of the existing keys are preserved as well.
+// A new file is created with 0600 permissions. It fails if c has no Options yet, see Use.
+func (c *Config) WriteUserConfig(values map[string]interface{}) error {
+	if c.opts == nil {
+		return errors.New("cannot write the user config without Options, see Config.Use")
+	}
+	configFile, err := c.opts.UserConfigFile()
+	if err != nil {
+		return err
+	}
+	if err = writeConfigFile(configFile, values, file.IsFile(configFile)); err != nil {
+		return err
+	}
+	logger.Debugf("wrote %d key(s) to '%s'", len(values), configFile)
+	_, err = c.reload()
+	return err
+}
+
+// writeConfigFile merges values into configFile, or into a new file if merge is false
+func writeConfigFile(configFile string, values map[string]interface{}, merge bool) error {
+	if merge && containsString([]string{"yaml", "yml"}, strings.TrimPrefix(filepath.Ext(configFile), ".")) {
+		return mergeYAMLFile(configFile, values)
+	}
+	w := newViper()
+	w.SetConfigFile(configFile)
+	w.SetConfigPermissions(0o600)
+	if merge {
+		if err := w.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file '%s': %w", configFile, err)
+		}
+	}
+	for k, v := range values {
+		w.Set(k, v)
+	}
+	if err := os.MkdirAll(filepath.Dir(configFile), 0o700); err != nil {
+		return err
+	}
+	return w.WriteConfigAs(configFile)
+}
+
+// mergeYAMLFile sets values in the yaml configFile by editing its node tree, so comments, key order and key case
+// are kept. Existing keys are matched case insensitively, like viper does
+func mergeYAMLFile(configFile string, values map[string]interface{}) error {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file '%s': %w", configFile, err)
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("failed to read config file '%s': %w", configFile, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	// new keys are appended in a stable order
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err = setYAMLValue(doc.Content[0], strings.Split(k, "."), values[k]); err != nil {
+			return fmt.Errorf("failed to set '%s' in config file '%s': %w", k, configFile, err)
+		}
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return err
+	}
+	if err = encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(configFile, out.Bytes(), 0o600)
+}
+
+// setYAMLValue sets value at the path of keys in the mapping node, creating the missing sections.
+// The comments of a replaced value are kept
+func setYAMLValue(node *yaml.Node, keys []string, value interface{}) error {
+	if node.Kind != yaml.MappingNode {
+		// a scalar or a list replaced by a section
+		*node = yaml.Node{Kind: yaml.MappingNode, HeadComment: node.HeadComment, LineComment: node.LineComment}
+	}
+	var child *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, keys[0]) {
+			child = node.Content[i+1]
+			break
+		}
+	}
+	if child == nil {
+		child = &yaml.Node{Kind: yaml.MappingNode}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: keys[0]}, child)
+	}
+	if len(keys) > 1 {
+		return setYAMLValue(child, keys[1:], value)
+	}
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return err
+	}
+	encoded.HeadComment, encoded.LineComment, encoded.FootComment = child.HeadComment, child.LineComment, child.FootComment
+	*child = encoded
+	return nil
+}
+
+// Defaults returns the default value of every key known to JSONSchema, or its zero value when there is no default.
+// Useful to scaffold a config file
+func (c *Config) Defaults() map[string]interface{} {
+	defaultValues := make(map[string]interface{})
+	var walk func(prefix string, s *Schema)
+	walk = func(prefix string, s *Schema) {
+		if s.Type == "object" && len(s.Properties) > 0 {
+			for k, child := range s.Properties {
+				walk(stringutil.ConcatStrings(prefix, k, "."), child)
+			}
+			return
+		}
+		key := strings.TrimSuffix(prefix, ".")
+		switch {
+		case s.Default != nil:
+			defaultValues[key] = s.Default
+		case s.Type == "array":
+			defaultValues[key] = []interface{}{}
+		case s.Type == "object":
+			defaultValues[key] = map[string]interface{}{}
+		case s.Type == "boolean":
+			defaultValues[key] = false
+		case s.Type == "integer" || s.Type == "number":
+			defaultValues[key] = 0
+		default:
+			defaultValues[key] = ""
+		}
+	}
+	walk("", c.JSONSchema())
+	// a new config file has the current version, which is only written when there are migrations
+	delete(defaultValues, defaults.VersionKey)
+	if c.opts != nil && c.opts.ConfigVersion() > 0 {
+		defaultValues[defaults.VersionKey] = c.opts.ConfigVersion()
+	}
+	return defaultValues
+}
+
+// ParseValue converts raw to the type JSONSchema declares for key. Unknown keys are kept as strings
+func (c *Config) ParseValue(key, raw string) (interface{}, error) {
+	s := c.JSONSchema()
+	for _, segment := range strings.Split(strings.ToLower(key), ".") {
+		child, ok := s.Properties[segment]
+		if !ok {
+			return raw, nil
+		}
+		s = child
+	}
+	switch s.Type {
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "integer":
+		return strconv.ParseInt(raw, 10, 64)
+	case "number":
+		return strconv.ParseFloat(raw, 64)
+	case "array":
+		if raw == "" {
+			return []string{}, nil
+		}
+		return strings.Split(raw, ","), nil
+	case "object":
+		return nil, fmt.Errorf("'%s' is a section, set its keys instead", key)
+	}
+	return raw, nil
+}
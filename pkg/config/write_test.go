@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newWriteTestConfig(t *testing.T, appHome string) *Config {
+	t.Helper()
+	c := newSourceTestConfig(t, []string{appHome}, WithAppHome(appHome), WithConfigName("app"))
+	_, deploy, _ := newKeyTestCommands()
+	if err := c.RegisterSchema(deploy, &testSchemaConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestParseValue(t *testing.T) {
+	c := newWriteTestConfig(t, t.TempDir())
+	tests := []struct {
+		key      string
+		raw      string
+		expected interface{}
+		err      string
+	}{
+		{"deploy.port", "443", int64(443), ""},
+		{"deploy.port", "x", nil, "invalid syntax"},
+		{"Deploy.Mode", "fast", "fast", ""},
+		{"deploy.servers", "a,b", []string{"a", "b"}, ""},
+		{"deploy.servers", "", []string{}, ""},
+		{"log-file-compress", "false", false, ""},
+		{"deploy", "x", nil, "is a section"},
+		{"unknown.key", "1", "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.raw, func(t *testing.T) {
+			value, err := c.ParseValue(tt.key, tt.raw)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	values := newWriteTestConfig(t, t.TempDir()).Defaults()
+	tests := []struct {
+		key      string
+		expected interface{}
+	}{
+		{"deploy.port", float64(8080)},
+		{"deploy.mode", ""},
+		{"deploy.servers", []interface{}{}},
+		{"log-file-compress", true},
+		{"log-stderr", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if value, ok := values[tt.key]; !ok || !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+	if _, ok := values["version"]; ok {
+		t.Error("Expected no version without migrations")
+	}
+}
+
+func TestWriteUserConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing is the name and content of the config file in AppHome, if any
+		existing map[string]string
+		values   map[string]interface{}
+		file     string
+		contains []string
+	}{
+		{
+			name:     "new file",
+			values:   map[string]interface{}{"deploy.port": 443},
+			file:     "app.yaml",
+			contains: []string{"deploy:\n    port: 443\n"},
+		},
+		{
+			name:     "existing yaml",
+			existing: map[string]string{"app.yaml": "name: app\npassword: ${env:TEST_PASSWORD}\n"},
+			values:   map[string]interface{}{"deploy.mode": "fast"},
+			file:     "app.yaml",
+			contains: []string{"name: app", "password: ${env:TEST_PASSWORD}", "mode: fast"},
+		},
+		{
+			name: "existing yaml with comments",
+			existing: map[string]string{
+				"app.yaml": "# app settings\nName: app\nDeploy:\n  # the port\n  Port: 80 # default\n",
+			},
+			values: map[string]interface{}{"deploy.port": 443, "deploy.mode": "fast"},
+			file:   "app.yaml",
+			contains: []string{
+				"# app settings\nName: app\n",
+				"Deploy:\n  # the port\n  Port: 443 # default\n  mode: fast\n",
+			},
+		},
+		{
+			name:     "existing json",
+			existing: map[string]string{"app.json": `{"name": "app"}`},
+			values:   map[string]interface{}{"deploy.port": 80},
+			file:     "app.json",
+			contains: []string{`"name": "app"`, `"port": 80`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_PASSWORD", "s3cret")
+			appHome := t.TempDir()
+			for name, content := range tt.existing {
+				writeTestFile(t, filepath.Join(appHome, name), content)
+			}
+			c := newWriteTestConfig(t, appHome)
+			if err := c.InitConfig(); err != nil {
+				t.Fatal(err)
+			}
+			configFile, err := c.Options().UserConfigFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(appHome, tt.file); configFile != expected {
+				t.Errorf("Expected the user config file '%s', got '%s'", expected, configFile)
+			}
+			if err = c.WriteUserConfig(tt.values); err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(content), s) {
+					t.Errorf("Expected '%s' in '%s'", s, content)
+				}
+			}
+			if info, _ := os.Stat(configFile); tt.existing == nil && info.Mode().Perm() != 0o600 {
+				t.Errorf("Expected a new file with 0600 permissions, got %s", info.Mode())
+			}
+			for key, value := range tt.values {
+				if c.Viper().GetString(key) != fmt.Sprint(value) {
+					t.Errorf("Expected '%s' to be reloaded as %v, got %v", key, value, c.Viper().Get(key))
+				}
+			}
+		})
+	}
+}
+
+func TestWriteUserConfigWithoutOptions(t *testing.T) {
+	c := &Config{viper: newViper()}
+	if err := c.WriteUserConfig(map[string]interface{}{"name": "app"}); err == nil {
+		t.Error("Expected an error without Options")
+	}
+}
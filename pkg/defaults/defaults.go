@@ -1,8 +1,18 @@
 package defaults
 
 const (
-	ViperEnvPrefix = "MY"
-	LogLevelKey    = "log-level"
-	LogFormatKey   = "log-format"
-	Undefined      = "<undefined>"
+	ViperEnvPrefix       = "MY"
+	LogLevelKey          = "log-level"
+	LogFormatKey         = "log-format"
+	LogFileKey           = "log-file"
+	LogFileMaxSizeKey    = "log-file-max-size"
+	LogFileMaxAgeKey     = "log-file-max-age"
+	LogFileMaxBackupsKey = "log-file-max-backups"
+	LogFileCompressKey   = "log-file-compress"
+	LogStderrKey         = "log-stderr"
+	ProfileKey           = "profile"
+	ProfilesKey          = "profiles"
+	IncludeKey           = "include"
+	VersionKey           = "version"
+	Undefined            = "<undefined>"
 )
@@ -4,8 +4,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-
-	"github.com/thedataflows/go-commons/pkg/process"
 )
 
 // TrimExtension returns file path without extension
@@ -21,28 +19,11 @@ func AppendExtension(fileName string) string {
 	return fileName
 }
 
-// AppHome returns a directory path in the user home and creates it if needed
+// AppHome returns the program config directory in the user home and creates it if needed, see AppDir
 //
 // if programPath is empty, current running process is used to extract program name
 func AppHome(programPath string) (string, error) {
-	var err error
-	if programPath == "" {
-		programPath, err = process.CurrentProcessPathE()
-		if err != nil {
-			return "", err
-		}
-	}
-	programName := TrimExtension(filepath.Base(programPath))
-	userHome, err := os.UserConfigDir()
-	if err != nil {
-		return "", err
-	}
-	programHome := filepath.Join(userHome, programName)
-	err = os.MkdirAll(programHome, 0700)
-	if err != nil {
-		return programHome, err
-	}
-	return programHome, nil
+	return AppDir(programPath, ConfigDir)
 }
 
 // WorkingDirectory returns the current working directory or empty on error
@@ -0,0 +1,279 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/thedataflows/go-commons/pkg/process"
+)
+
+// goos is runtime.GOOS, replaced by tests
+var goos = runtime.GOOS
+
+// BaseDir is a kind of XDG base directory
+type BaseDir int
+
+const (
+	// ConfigDir holds user configuration, $XDG_CONFIG_HOME
+	ConfigDir BaseDir = iota
+	// DataDir holds user data, $XDG_DATA_HOME
+	DataDir
+	// CacheDir holds non-essential cached data, $XDG_CACHE_HOME
+	CacheDir
+	// StateDir holds state that should persist between restarts, like logs or history, $XDG_STATE_HOME
+	StateDir
+	// RuntimeDir holds runtime files like sockets or pid files, $XDG_RUNTIME_DIR
+	RuntimeDir
+)
+
+func (d BaseDir) String() string {
+	switch d {
+	case ConfigDir:
+		return "config"
+	case DataDir:
+		return "data"
+	case CacheDir:
+		return "cache"
+	case StateDir:
+		return "state"
+	case RuntimeDir:
+		return "runtime"
+	}
+	return fmt.Sprintf("BaseDir(%d)", int(d))
+}
+
+// envVar returns the XDG environment variable overriding the base directory
+func (d BaseDir) envVar() string {
+	switch d {
+	case ConfigDir:
+		return "XDG_CONFIG_HOME"
+	case DataDir:
+		return "XDG_DATA_HOME"
+	case CacheDir:
+		return "XDG_CACHE_HOME"
+	case StateDir:
+		return "XDG_STATE_HOME"
+	case RuntimeDir:
+		return "XDG_RUNTIME_DIR"
+	}
+	return ""
+}
+
+// UserBaseDir returns the user base directory of kind d.
+//
+// The XDG environment variable is used when set to an absolute path, as the specification requires.
+// Otherwise the platform default is used: ~/.config, ~/.local/share, ~/.cache and ~/.local/state on unix,
+// and a per user directory in os.TempDir for runtime, see tempRuntimeDir. Windows and macOS only have
+// os.UserConfigDir and os.UserCacheDir, so data and state share the config directory, see AppDir
+func UserBaseDir(d BaseDir) (string, error) {
+	if dir := os.Getenv(d.envVar()); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	if d == RuntimeDir {
+		return tempRuntimeDir()
+	}
+	if sharedBaseDirs() {
+		if d == CacheDir {
+			return os.UserCacheDir()
+		}
+		return os.UserConfigDir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch d {
+	case ConfigDir:
+		return filepath.Join(home, ".config"), nil
+	case DataDir:
+		return filepath.Join(home, ".local", "share"), nil
+	case CacheDir:
+		return filepath.Join(home, ".cache"), nil
+	case StateDir:
+		return filepath.Join(home, ".local", "state"), nil
+	}
+	return "", fmt.Errorf("unknown base directory %s", d)
+}
+
+// sharedBaseDirs returns true on the platforms where data and state have no base directory of their own
+func sharedBaseDirs() bool {
+	switch goos {
+	case "windows", "darwin", "ios", "plan9":
+		return true
+	}
+	return false
+}
+
+// appSubdir returns the directory holding the files of kind d inside the program directory,
+// on the platforms where the base directory is shared with the config. Empty otherwise
+func (d BaseDir) appSubdir() string {
+	if !sharedBaseDirs() || filepath.IsAbs(os.Getenv(d.envVar())) {
+		return ""
+	}
+	switch d {
+	case DataDir, StateDir:
+		return d.String()
+	}
+	return ""
+}
+
+// tempRuntimeDir returns the per user runtime directory in os.TempDir, created with mode 0700 when missing.
+// Since other users can create entries in os.TempDir, an existing one is rejected when it is not a directory,
+// like a symlink, or when it is not owned by the current user and only accessible by them
+func tempRuntimeDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), runtimeDirName())
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		if err = os.Mkdir(dir, 0o700); err != nil && !os.IsExist(err) {
+			return dir, err
+		}
+		// created by another process in between, check it like an existing one
+		info, err = os.Lstat(dir)
+	}
+	if err != nil {
+		return dir, err
+	}
+	if !info.IsDir() {
+		return dir, fmt.Errorf("unsafe runtime directory '%s': it is not a directory", dir)
+	}
+	if err = checkRuntimeDirOwner(info); err != nil {
+		return dir, fmt.Errorf("unsafe runtime directory '%s': %w", dir, err)
+	}
+	return dir, nil
+}
+
+// runtimeDirName returns the per user name of the runtime directory in os.TempDir
+func runtimeDirName() string {
+	if uid := os.Getuid(); uid >= 0 {
+		return fmt.Sprintf("runtime-%d", uid)
+	}
+	// there is no uid on windows
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "runtime-" + strings.NewReplacer(`\`, "-", "/", "-", ":", "-").Replace(u.Username)
+	}
+	return "runtime"
+}
+
+// ConfigDirs returns the system config directories from $XDG_CONFIG_DIRS, in order of preference.
+// Relative entries are ignored. Defaults to /etc/xdg on unix and to none elsewhere
+func ConfigDirs() []string {
+	value := os.Getenv("XDG_CONFIG_DIRS")
+	if value == "" {
+		if sharedBaseDirs() {
+			return nil
+		}
+		value = "/etc/xdg"
+	}
+	dirs := make([]string, 0)
+	for _, dir := range filepath.SplitList(value) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// AppDirOption customizes AppDir
+type AppDirOption func(*appDirOptions)
+
+type appDirOptions struct {
+	perm      os.FileMode
+	create    bool
+	legacyDir string
+}
+
+// WithPerm sets the permissions of a created directory. Defaults to 0700
+func WithPerm(perm os.FileMode) AppDirOption {
+	return func(o *appDirOptions) {
+		o.perm = perm
+	}
+}
+
+// WithCreate tells if the directory is created when missing. Defaults to true
+func WithCreate(create bool) AppDirOption {
+	return func(o *appDirOptions) {
+		o.create = create
+	}
+}
+
+// WithLegacyDir moves the content of legacyDir, an older location of the directory, into the new one.
+// Entries already present in the new directory are left in legacyDir, which is removed once empty
+func WithLegacyDir(legacyDir string) AppDirOption {
+	return func(o *appDirOptions) {
+		o.legacyDir = legacyDir
+	}
+}
+
+// AppDir returns the program directory inside the user base directory of kind d, see UserBaseDir.
+// When data and state share the config base directory, they are the data and state subdirectories
+// of the program config directory, so each kind has its own directory.
+//
+// if programPath is empty, current running process is used to extract program name
+func AppDir(programPath string, d BaseDir, options ...AppDirOption) (string, error) {
+	opts := appDirOptions{perm: 0o700, create: true}
+	for _, o := range options {
+		o(&opts)
+	}
+	var err error
+	if programPath == "" {
+		programPath, err = process.CurrentProcessPathE()
+		if err != nil {
+			return "", err
+		}
+	}
+	baseDir, err := UserBaseDir(d)
+	if err != nil {
+		return "", err
+	}
+	appDir := filepath.Join(baseDir, TrimExtension(filepath.Base(programPath)), d.appSubdir())
+	if opts.legacyDir != "" {
+		if err = migrateDir(opts.legacyDir, appDir, opts.perm); err != nil {
+			return appDir, fmt.Errorf("failed to migrate '%s' to '%s': %w", opts.legacyDir, appDir, err)
+		}
+	}
+	if opts.create {
+		if err = os.MkdirAll(appDir, opts.perm); err != nil {
+			return appDir, err
+		}
+	}
+	return appDir, nil
+}
+
+// migrateDir moves the entries of legacyDir into dir, then removes legacyDir if empty
+func migrateDir(legacyDir, dir string, perm os.FileMode) error {
+	if !IsDirectory(legacyDir) || filepath.Clean(legacyDir) == filepath.Clean(dir) {
+		return nil
+	}
+	if !IsAccessible(dir) {
+		if err := os.MkdirAll(filepath.Dir(dir), perm); err != nil {
+			return err
+		}
+		if err := os.Rename(legacyDir, dir); err == nil {
+			return os.Chmod(dir, perm)
+		}
+		// most likely on another device, moving entry by entry will tell
+		if err := os.MkdirAll(dir, perm); err != nil {
+			return err
+		}
+	}
+	entries, err := os.ReadDir(legacyDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		target := filepath.Join(dir, entry.Name())
+		if IsAccessible(target) {
+			continue
+		}
+		if err = os.Rename(filepath.Join(legacyDir, entry.Name()), target); err != nil {
+			return err
+		}
+	}
+	// fails when entries were left behind, which is expected
+	_ = os.Remove(legacyDir)
+	return nil
+}
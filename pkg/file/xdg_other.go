@@ -0,0 +1,10 @@
+//go:build !unix
+
+package file
+
+import "os"
+
+// checkRuntimeDirOwner does nothing: there are no unix owner and permissions to check
+func checkRuntimeDirOwner(os.FileInfo) error {
+	return nil
+}
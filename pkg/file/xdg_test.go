@@ -0,0 +1,229 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// setXDGTestEnv points HOME and TMPDIR to temporary directories, clears the XDG variables and sets goos for the test
+func setXDGTestEnv(t *testing.T, testGOOS string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMPDIR", t.TempDir())
+	for _, d := range []BaseDir{ConfigDir, DataDir, CacheDir, StateDir, RuntimeDir} {
+		t.Setenv(d.envVar(), "")
+	}
+	previous := goos
+	goos = testGOOS
+	t.Cleanup(func() { goos = previous })
+	return home
+}
+
+func TestUserBaseDir(t *testing.T) {
+	home := setXDGTestEnv(t, "linux")
+	xdgHome := filepath.Join(home, "xdg")
+	tests := []struct {
+		dir      BaseDir
+		env      string
+		expected string
+	}{
+		{ConfigDir, "", filepath.Join(home, ".config")},
+		{DataDir, "", filepath.Join(home, ".local", "share")},
+		{CacheDir, "", filepath.Join(home, ".cache")},
+		{StateDir, "", filepath.Join(home, ".local", "state")},
+		{RuntimeDir, "", filepath.Join(os.TempDir(), fmt.Sprintf("runtime-%d", os.Getuid()))},
+		{ConfigDir, xdgHome, xdgHome},
+		{StateDir, xdgHome, xdgHome},
+		{RuntimeDir, xdgHome, xdgHome},
+		// relative paths are ignored, as the specification requires
+		{DataDir, "relative", filepath.Join(home, ".local", "share")},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.dir, tt.env), func(t *testing.T) {
+			t.Setenv(tt.dir.envVar(), tt.env)
+			dir, err := UserBaseDir(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, dir)
+			}
+		})
+	}
+}
+
+func TestUnsafeRuntimeDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions on windows")
+	}
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+		err   string
+	}{
+		{name: "missing", setup: func(*testing.T, string) {}},
+		{name: "existing", setup: func(t *testing.T, dir string) { mkdirTest(t, dir, 0o700) }},
+		{name: "group readable", setup: func(t *testing.T, dir string) { mkdirTest(t, dir, 0o750) },
+			err: "its mode is 0750"},
+		{name: "file", setup: func(t *testing.T, dir string) {
+			writeEntries(t, filepath.Dir(dir), []string{filepath.Base(dir)}, "")
+		}, err: "it is not a directory"},
+		{name: "symlink", setup: func(t *testing.T, dir string) {
+			target := filepath.Join(t.TempDir(), "target")
+			mkdirTest(t, target, 0o700)
+			if err := os.Symlink(target, dir); err != nil {
+				t.Fatal(err)
+			}
+		}, err: "it is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setXDGTestEnv(t, "linux")
+			expected := filepath.Join(os.TempDir(), fmt.Sprintf("runtime-%d", os.Getuid()))
+			tt.setup(t, expected)
+			dir, err := UserBaseDir(RuntimeDir)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expected an error containing '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Lstat(dir); err != nil || !info.IsDir() || info.Mode().Perm() != 0o700 {
+				t.Errorf("Expected '%s' to be a directory with mode 0700, got %v", dir, info)
+			}
+		})
+	}
+}
+
+func mkdirTest(t *testing.T, dir string, perm os.FileMode) {
+	t.Helper()
+	if err := os.Mkdir(dir, perm); err != nil {
+		t.Fatal(err)
+	}
+	// not restricted by the umask
+	if err := os.Chmod(dir, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppDirsAreDistinct(t *testing.T) {
+	for _, testGOOS := range []string{"linux", "darwin", "windows"} {
+		t.Run(testGOOS, func(t *testing.T) {
+			setXDGTestEnv(t, testGOOS)
+			seen := make(map[string]BaseDir)
+			for _, d := range []BaseDir{ConfigDir, DataDir, CacheDir, StateDir, RuntimeDir} {
+				dir, err := AppDir("/usr/bin/app.exe", d, WithCreate(false))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if filepath.Base(filepath.Dir(dir)) != "app" && filepath.Base(dir) != "app" {
+					t.Errorf("Expected the %s directory to be named after the program, got '%s'", d, dir)
+				}
+				if other, ok := seen[dir]; ok {
+					t.Errorf("Expected distinct directories, %s and %s are both '%s'", other, d, dir)
+				}
+				seen[dir] = d
+			}
+		})
+	}
+}
+
+func TestConfigDirs(t *testing.T) {
+	tests := []struct {
+		goos     string
+		env      string
+		expected []string
+	}{
+		{"linux", "", []string{"/etc/xdg"}},
+		{"linux", "/etc/a" + string(os.PathListSeparator) + "relative" + string(os.PathListSeparator) + "/etc/b",
+			[]string{"/etc/a", "/etc/b"}},
+		{"darwin", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+" "+tt.env, func(t *testing.T) {
+			setXDGTestEnv(t, tt.goos)
+			t.Setenv("XDG_CONFIG_DIRS", tt.env)
+			dirs := ConfigDirs()
+			if fmt.Sprint(dirs) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, dirs)
+			}
+		})
+	}
+}
+
+func TestAppDirLegacyMigration(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing holds the entries already in the new directory, nil when it does not exist
+		existing []string
+		legacy   []string
+		// moved are the entries expected in the new directory, left the ones expected to stay in the legacy one
+		moved []string
+		left  []string
+	}{
+		{name: "new directory missing", legacy: []string{"a", "b"}, moved: []string{"a", "b"}},
+		{name: "new directory existing", existing: []string{"a"}, legacy: []string{"a", "b"}, moved: []string{"b"},
+			left: []string{"a"}},
+		{name: "no legacy directory", existing: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setXDGTestEnv(t, "linux")
+			configHome := filepath.Join(home, "config")
+			t.Setenv("XDG_CONFIG_HOME", configHome)
+			appDir := filepath.Join(configHome, "app")
+			legacyDir := filepath.Join(home, ".app")
+			writeEntries(t, appDir, tt.existing, "new")
+			writeEntries(t, legacyDir, tt.legacy, "legacy")
+
+			dir, err := AppDir("app", ConfigDir, WithLegacyDir(legacyDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != appDir {
+				t.Fatalf("Expected '%s', got '%s'", appDir, dir)
+			}
+			for _, name := range tt.moved {
+				if content, _ := os.ReadFile(filepath.Join(appDir, name)); string(content) != "legacy" {
+					t.Errorf("Expected '%s' moved to the new directory, got '%s'", name, content)
+				}
+			}
+			for _, name := range tt.existing {
+				if content, _ := os.ReadFile(filepath.Join(appDir, name)); string(content) != "new" {
+					t.Errorf("Expected '%s' of the new directory to be kept, got '%s'", name, content)
+				}
+			}
+			for _, name := range tt.left {
+				if !IsFile(filepath.Join(legacyDir, name)) {
+					t.Errorf("Expected '%s' to be left in the legacy directory", name)
+				}
+			}
+			if IsAccessible(legacyDir) != (len(tt.left) > 0) {
+				t.Errorf("Expected the legacy directory to be removed only when emptied")
+			}
+		})
+	}
+}
+
+func writeEntries(t *testing.T, dir string, names []string, content string) {
+	t.Helper()
+	if names == nil {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkRuntimeDirOwner returns an error unless info, the runtime directory in os.TempDir,
+// is owned by the current user and only accessible by them
+func checkRuntimeDirOwner(info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("it is owned by uid %d instead of %d", stat.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		return fmt.Errorf("its mode is %#o instead of 0700", perm)
+	}
+	return nil
+}
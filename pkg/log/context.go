@@ -0,0 +1,63 @@
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying l, see FromContext
+func WithContext(ctx context.Context, l *CustomLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the global Log if there is none
+func FromContext(ctx context.Context) *CustomLogger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(*CustomLogger); ok && l != nil {
+			return l
+		}
+	}
+	return &Log
+}
+
+func TraceContext(ctx context.Context, i ...interface{}) {
+	FromContext(ctx).GetLogger().Trace().Msg(fmt.Sprint(i...))
+}
+
+func TracefContext(ctx context.Context, format string, i ...interface{}) {
+	FromContext(ctx).GetLogger().Trace().Msgf(format, i...)
+}
+
+func DebugContext(ctx context.Context, i ...interface{}) {
+	FromContext(ctx).GetLogger().Debug().Msg(fmt.Sprint(i...))
+}
+
+func DebugfContext(ctx context.Context, format string, i ...interface{}) {
+	FromContext(ctx).GetLogger().Debug().Msgf(format, i...)
+}
+
+func InfoContext(ctx context.Context, i ...interface{}) {
+	FromContext(ctx).GetLogger().Info().Msg(fmt.Sprint(i...))
+}
+
+func InfofContext(ctx context.Context, format string, i ...interface{}) {
+	FromContext(ctx).GetLogger().Info().Msgf(format, i...)
+}
+
+func WarnContext(ctx context.Context, i ...interface{}) {
+	FromContext(ctx).GetLogger().Warn().Msg(fmt.Sprint(i...))
+}
+
+func WarnfContext(ctx context.Context, format string, i ...interface{}) {
+	FromContext(ctx).GetLogger().Warn().Msgf(format, i...)
+}
+
+func ErrorContext(ctx context.Context, i ...interface{}) {
+	FromContext(ctx).GetLogger().Error().Msg(fmt.Sprint(i...))
+}
+
+func ErrorfContext(ctx context.Context, format string, i ...interface{}) {
+	FromContext(ctx).GetLogger().Error().Msgf(format, i...)
+}
@@ -0,0 +1,316 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp of the rotated files names, sortable and safe in file names
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// renameFile renames the rotated files, replaced by tests
+var renameFile = os.Rename
+
+// FileOptions configures a log file and its rotation
+type FileOptions struct {
+	// Path of the log file, its directory is created if missing
+	Path string
+	// MaxSize in bytes the file may reach before it is rotated, 0 for no limit
+	MaxSize int64
+	// MaxAge of the file, since it was opened, before it is rotated, 0 for no limit
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, 0 to keep them all
+	MaxBackups int
+	// Compress the rotated files with gzip
+	Compress bool
+}
+
+// RotatingFile is an io.Writer appending to a log file, rotated by size and age.
+// Rotated files are renamed to `<name>-<timestamp><ext>`, with a `-<counter>` after the timestamp when it is taken,
+// then compressed and pruned in the background
+type RotatingFile struct {
+	opts FileOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	// closed is set by Close. Otherwise a nil file failed to open and is opened again by the next write
+	closed bool
+
+	// millMu serializes the compression and pruning of rotated files, millWg waits for them on Close
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+}
+
+// OpenRotatingFile opens, for appending, the log file described by opts
+func OpenRotatingFile(opts FileOptions) (*RotatingFile, error) {
+	if opts.Path == "" {
+		return nil, errors.New("log file path is empty")
+	}
+	f := &RotatingFile{opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Options returns the options f was opened with
+func (f *RotatingFile) Options() FileOptions {
+	return f.opts
+}
+
+// Write appends p to the log file, rotating it first if p would exceed MaxSize or the file is older than MaxAge
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ensureOpen(); err != nil {
+		return 0, err
+	}
+	if f.size > 0 && f.needsRotation(len(p)) {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// the log file is still open, keep appending to it
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the current log file to a backup and opens a new one
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.ensureOpen(); err != nil {
+		return err
+	}
+	return f.rotate()
+}
+
+// Reopen closes and opens again the log file path, picking up a file renamed by an external tool like logrotate
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes the log file and waits for the rotated files to be compressed and pruned
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	f.closed = true
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+	f.millWg.Wait()
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.opts.Path), 0o750); err != nil {
+		return fmt.Errorf("cannot create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("cannot open log file: %w", err)
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), time.Now()
+	return nil
+}
+
+// ensureOpen opens the log file again after a failed rotation or reopening
+func (f *RotatingFile) ensureOpen() error {
+	switch {
+	case f.closed:
+		return os.ErrClosed
+	case f.file == nil:
+		return f.open()
+	}
+	return nil
+}
+
+func (f *RotatingFile) needsRotation(n int) bool {
+	return (f.opts.MaxSize > 0 && f.size+int64(n) > f.opts.MaxSize) ||
+		(f.opts.MaxAge > 0 && time.Since(f.openedAt) >= f.opts.MaxAge)
+}
+
+// rotate renames the log file to a backup and opens a new one. When renaming or opening fails,
+// the log file is opened again at its path, so writes go on
+func (f *RotatingFile) rotate() error {
+	// the file is opened again below, even when closing failed
+	closeErr := f.file.Close()
+	f.file = nil
+	backup := f.backupName()
+	renameErr := renameFile(f.opts.Path, backup)
+	renamed := renameErr == nil
+	if renameErr != nil && !errors.Is(renameErr, fs.ErrNotExist) {
+		renameErr = fmt.Errorf("cannot rotate log file: %w", renameErr)
+	} else {
+		// a missing file, removed by an external tool, is simply opened again
+		renameErr = nil
+	}
+	if err := f.open(); err != nil {
+		if !renamed {
+			return err
+		}
+		// keep appending to the previous file
+		if renameBackErr := renameFile(backup, f.opts.Path); renameBackErr != nil {
+			return errors.Join(err, renameBackErr)
+		}
+		if reopenErr := f.open(); reopenErr != nil {
+			return errors.Join(err, reopenErr)
+		}
+		return fmt.Errorf("cannot rotate log file: %w", err)
+	}
+	if renamed {
+		f.millWg.Add(1)
+		go f.mill(backup)
+	}
+	return errors.Join(renameErr, closeErr)
+}
+
+// backupName returns a name for the next rotated file, not taken by another one, compressed or not
+func (f *RotatingFile) backupName() string {
+	ext := filepath.Ext(f.opts.Path)
+	name := strings.TrimSuffix(f.opts.Path, ext) + "-" + time.Now().Format(backupTimeFormat)
+	backup := name + ext
+	for i := 1; fileExists(backup) || fileExists(backup+".gz"); i++ {
+		backup = name + "-" + strconv.Itoa(i) + ext
+	}
+	return backup
+}
+
+// mill compresses backup and removes the oldest backups beyond MaxBackups.
+// Errors go to stderr, as logging them could write to this very file
+func (f *RotatingFile) mill(backup string) {
+	defer f.millWg.Done()
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+	if f.opts.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot compress rotated log file '%s': %s\n", backup, err)
+		}
+	}
+	if f.opts.MaxBackups <= 0 {
+		return
+	}
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot list rotated log files: %s\n", err)
+		return
+	}
+	for i := 0; i < len(backups)-f.opts.MaxBackups; i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot remove rotated log file '%s': %s\n", backups[i], err)
+		}
+	}
+}
+
+// backups returns the rotated files of the log file, oldest first
+func (f *RotatingFile) backups() ([]string, error) {
+	dir := filepath.Dir(f.opts.Path)
+	ext := filepath.Ext(f.opts.Path)
+	prefix := strings.TrimSuffix(filepath.Base(f.opts.Path), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		path    string
+		time    time.Time
+		counter int
+	}
+	found := make([]backup, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		timestamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		counter := 0
+		// the timestamp ends with the milliseconds after a dot, so a dash then digits is a counter
+		if i := strings.LastIndex(timestamp, "-"); i > strings.LastIndex(timestamp, ".") {
+			if counter, err = strconv.Atoi(timestamp[i+1:]); err != nil {
+				continue
+			}
+			timestamp = timestamp[:i]
+		}
+		t, err := time.Parse(backupTimeFormat, timestamp)
+		if err != nil {
+			continue
+		}
+		found = append(found, backup{path: filepath.Join(dir, name), time: t, counter: counter})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].time.Equal(found[j].time) {
+			return found[i].time.Before(found[j].time)
+		}
+		return found[i].counter < found[j].counter
+	})
+	backups := make([]string, 0, len(found))
+	for _, b := range found {
+		backups = append(backups, b.path)
+	}
+	return backups, nil
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// gzipFile replaces name by its gzip compressed copy, name.gz
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "app.log")
+	f, err := OpenRotatingFile(FileOptions{Path: path, MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		// rotations within the same millisecond get distinct names
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "fourth\n" {
+		t.Errorf("Expected the log file to hold the last line, got '%s'", content)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".log.gz") {
+			t.Errorf("Expected a compressed backup, got '%s'", b)
+		}
+	}
+
+	f, err = OpenRotatingFile(FileOptions{Path: path, MaxAge: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	time.Sleep(2 * time.Millisecond)
+	if _, err = f.Write([]byte("fifth\n")); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ = os.ReadFile(path); string(content) != "fifth\n" {
+		t.Errorf("Expected the log file to be rotated by age, got '%s'", content)
+	}
+}
+
+func TestRotatingFileRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(FileOptions{Path: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	renameFile = func(string, string) error { return os.ErrPermission }
+	defer func() { renameFile = os.Rename }()
+	for _, line := range []string{"first\n", "second\n"} {
+		if _, err = f.Write([]byte(line)); err != nil {
+			t.Fatalf("Expected writes to go on when rotating fails, got %s", err)
+		}
+	}
+	if content, _ := os.ReadFile(path); string(content) != "first\nsecond\n" {
+		t.Errorf("Expected the log file to be appended to, got '%s'", content)
+	}
+
+	// a log file removed by an external tool is created again
+	renameFile = os.Rename
+	if err = os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("third\n")); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "third\n" {
+		t.Errorf("Expected a new log file, got '%s'", content)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected %s after Close, got %v", os.ErrClosed, err)
+	}
+}
+
+func TestSetLogFileWhileLogging(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}
+	if err := SetLogFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = SetLogFile(FileOptions{}, true)
+		_ = SetLogFormat(FormatConsole)
+	}()
+	if err := SetLogFile(FileOptions{Path: paths[0]}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, lines = 4, 200
+	child := Log.WithFields("test", "switch")
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				child.Warnf("line %d", j)
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if err := SetLogFile(FileOptions{Path: paths[i%2]}, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if err := SetLogFile(FileOptions{}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lines written while the files switched all landed in one of them
+	count := 0
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += bytes.Count(content, []byte("\n"))
+	}
+	if count != writers*lines {
+		t.Errorf("Expected %d lines in the log files, got %d", writers*lines, count)
+	}
+}
@@ -0,0 +1,137 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Built-in log formats
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+	FormatPlain   = "plain"
+)
+
+// FormatWriter returns a writer encoding the JSON events of the logger to out.
+// color tells whether out accepts ANSI colors, which is false for log files
+type FormatWriter func(out io.Writer, color bool) io.Writer
+
+// formatWriters holds the registered log formats, guarded by outputMu
+var formatWriters = map[string]FormatWriter{
+	FormatConsole: func(out io.Writer, color bool) io.Writer {
+		return zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339, NoColor: !color}
+	},
+	FormatJSON: func(out io.Writer, _ bool) io.Writer {
+		return out
+	},
+	FormatLogfmt: func(out io.Writer, _ bool) io.Writer {
+		return &logfmtWriter{out: out}
+	},
+	FormatPlain: func(out io.Writer, _ bool) io.Writer {
+		return &logfmtWriter{out: out, plain: true}
+	},
+}
+
+// RegisterLogFormat registers fn as the log format name, replacing any previous one, and adds name to LogFormats.
+// Register formats before reading LogFormats, usually from an init function
+func RegisterLogFormat(name string, fn FormatWriter) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if _, ok := formatWriters[name]; !ok {
+		LogFormats = append(LogFormats, name)
+	}
+	formatWriters[name] = fn
+}
+
+// Formats returns a copy of LogFormats, the registered log formats, the default first
+func Formats() []string {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	return append([]string(nil), LogFormats...)
+}
+
+// logfmtWriter writes events as logfmt, `time=... level=... msg=... key=value`, sorted by key after the message.
+// The plain variant writes `LEVEL message key=value`, for destinations adding their own timestamps
+type logfmtWriter struct {
+	out   io.Writer
+	plain bool
+}
+
+func (w *logfmtWriter) Write(p []byte) (int, error) {
+	var event map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&event); err != nil {
+		return 0, fmt.Errorf("cannot decode log event: %w", err)
+	}
+
+	var buf bytes.Buffer
+	level, _ := event[zerolog.LevelFieldName].(string)
+	message, _ := event[zerolog.MessageFieldName].(string)
+	if w.plain {
+		buf.WriteString(strings.ToUpper(level))
+		buf.WriteByte(' ')
+		buf.WriteString(message)
+	} else {
+		if t, ok := event[zerolog.TimestampFieldName]; ok {
+			writeLogfmtPair(&buf, "time", t)
+		}
+		writeLogfmtPair(&buf, "level", level)
+		writeLogfmtPair(&buf, "msg", message)
+	}
+	delete(event, zerolog.TimestampFieldName)
+	delete(event, zerolog.LevelFieldName)
+	delete(event, zerolog.MessageFieldName)
+
+	keys := make([]string, 0, len(event))
+	for k := range event {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeLogfmtPair(&buf, k, event[k])
+	}
+	buf.WriteByte('\n')
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key string, value interface{}) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case json.Number:
+		buf.WriteString(v.String())
+		return
+	case nil:
+		return
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprint(v)
+		} else {
+			s = string(b)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		s = strconv.Quote(s)
+	}
+	buf.WriteString(s)
+}
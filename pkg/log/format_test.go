@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSetLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	RegisterLogFormat("capture", func(_ io.Writer, _ bool) io.Writer { return &buf })
+	defer func() { _ = SetLogFormat(FormatConsole) }()
+
+	if err := SetLogFormat("capture"); err != nil {
+		t.Fatal(err)
+	}
+	Log.WithFields("component", "test").Warnf("captured %d", 1)
+	var event map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Expected a JSON event, got '%s': %s", buf.String(), err)
+	}
+	if event["message"] != "captured 1" || event["component"] != "test" {
+		t.Errorf("Unexpected event %v", event)
+	}
+
+	if err := SetLogFormat(FormatConsole); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	Warn("not captured")
+	if buf.Len() > 0 {
+		t.Errorf("Expected the format to be switched back, got '%s'", buf.String())
+	}
+	if err := SetLogFormat("unknown"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestLogfmtFormats(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{FormatLogfmt, "level=warn msg=\"hello world\" component=search count=2 ok=true\n"},
+		{FormatPlain, "WARN hello world component=search count=2 ok=true\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := zerolog.New(formatWriters[tt.format](&buf, false))
+			logger.Warn().Str("component", "search").Int("count", 2).Bool("ok", true).Msg("hello world")
+			if buf.String() != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// ComponentKey is the field naming the component of a logger, see Component
+const ComponentKey = "component"
+
+var (
+	levelsMu        sync.RWMutex
+	componentLevels map[string]zerolog.Level
+)
+
+// LevelSpec is a parsed log level spec, like `warn,search=debug,config=trace`
+type LevelSpec struct {
+	// Level is the global level, nil when the spec only holds component levels
+	Level *zerolog.Level
+	// Components holds the levels of the named components
+	Components map[string]zerolog.Level
+}
+
+// ParseLevelSpec parses comma separated levels: a level alone is the global level,
+// `<component>=<level>` is the level of the loggers of that component, see Component. Empty items are skipped
+func ParseLevelSpec(spec string) (*LevelSpec, error) {
+	parsed := &LevelSpec{Components: make(map[string]zerolog.Level)}
+	for _, item := range strings.Split(spec, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		component, value, isComponent := strings.Cut(item, "=")
+		if !isComponent {
+			value = component
+		}
+		level, err := ParseLevel(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level spec '%s': %w", spec, err)
+		}
+		if !isComponent {
+			parsed.Level = &level
+			continue
+		}
+		component = strings.TrimSpace(component)
+		if component == "" {
+			return nil, fmt.Errorf("invalid log level spec '%s': empty component name", spec)
+		}
+		parsed.Components[component] = level
+	}
+	return parsed, nil
+}
+
+// LevelSpecPattern returns a regular expression matching valid level specs, see ParseLevelSpec
+func LevelSpecPattern() string {
+	item := fmt.Sprintf(`\s*([A-Za-z0-9_.-]+\s*=\s*)?(%s|-?[0-9]+)\s*`, strings.Join(AllLevelsValues, "|"))
+	return fmt.Sprintf(`^%s(,%s)*$`, item, item)
+}
+
+// Component returns a child logger of Log for the named component, honoring its level set by SetLogLevel
+func Component(name string) *CustomLogger {
+	return Log.WithFields(ComponentKey, name)
+}
+
+// ComponentLevel returns the level set for the component by SetLogLevel, if any
+func ComponentLevel(component string) (zerolog.Level, bool) {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	level, ok := componentLevels[component]
+	return level, ok
+}
+
+// SetLogLevel sets the levels of a spec like `warn,search=debug`, see ParseLevelSpec.
+// The global level is kept if the spec has none, while the component levels are all replaced.
+// Existing component loggers pick the new levels up on their next message
+func SetLogLevel(spec string) error {
+	parsed, err := ParseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+	if parsed.Level != nil {
+		Log.update(func(logger zerolog.Logger) zerolog.Logger { return logger.Level(*parsed.Level) })
+	}
+	levelsMu.Lock()
+	componentLevels = parsed.Components
+	levelsMu.Unlock()
+	generation.Add(1)
+	return nil
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseLevelSpec(t *testing.T) {
+	warn, debug, trace := WarnLevel, DebugLevel, TraceLevel
+	tests := []struct {
+		spec       string
+		level      *zerolog.Level
+		components map[string]zerolog.Level
+		invalid    bool
+	}{
+		{spec: "warn", level: &warn},
+		{spec: "warn,search=debug, config = trace", level: &warn,
+			components: map[string]zerolog.Level{"search": debug, "config": trace}},
+		{spec: "search=debug", components: map[string]zerolog.Level{"search": debug}},
+		{spec: "warn,search=loud", invalid: true},
+		{spec: "=debug", invalid: true},
+	}
+	pattern := regexp.MustCompile(LevelSpecPattern())
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if matched := pattern.MatchString(tt.spec); matched == tt.invalid {
+				t.Errorf("Expected the pattern to match: %v, got %v", !tt.invalid, matched)
+			}
+			parsed, err := ParseLevelSpec(tt.spec)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (parsed.Level == nil) != (tt.level == nil) || (tt.level != nil && *parsed.Level != *tt.level) {
+				t.Errorf("Expected level %v, got %v", tt.level, parsed.Level)
+			}
+			if len(parsed.Components) != len(tt.components) {
+				t.Errorf("Expected components %v, got %v", tt.components, parsed.Components)
+			}
+			for k, v := range tt.components {
+				if parsed.Components[k] != v {
+					t.Errorf("Expected %s=%s, got %s", k, v, parsed.Components[k])
+				}
+			}
+		})
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	root := NewLogger(zerolog.New(&buf).Level(WarnLevel))
+	search := root.WithFields(ComponentKey, "search").WithFields("request", "42")
+	other := root.WithFields(ComponentKey, "other")
+	defer func() { _ = SetLogLevel("") }()
+
+	if err := SetLogLevel("search=debug"); err != nil {
+		t.Fatal(err)
+	}
+	search.Debugf("search debug")
+	other.Debugf("other debug")
+	if !strings.Contains(buf.String(), "search debug") || strings.Contains(buf.String(), "other debug") {
+		t.Errorf("Expected only the search component at debug level, got '%s'", buf.String())
+	}
+
+	// levels change at runtime without new loggers
+	buf.Reset()
+	if err := SetLogLevel("other=debug"); err != nil {
+		t.Fatal(err)
+	}
+	search.Debugf("search debug")
+	other.Debugf("other debug")
+	if strings.Contains(buf.String(), "search debug") || !strings.Contains(buf.String(), "other debug") {
+		t.Errorf("Expected only the other component at debug level, got '%s'", buf.String())
+	}
+
+	// With keeps the zerolog context chain
+	buf.Reset()
+	zl := search.With().Str("step", "with").Logger()
+	zl.Warn().Msg("zerolog child")
+	if !strings.Contains(buf.String(), `"component":"search"`) || !strings.Contains(buf.String(), `"step":"with"`) {
+		t.Errorf("Expected the fields of the child and of With, got '%s'", buf.String())
+	}
+}
+
+func TestZerologMethods(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(zerolog.New(&buf).Level(WarnLevel))
+	// copies share the logger, as they did when CustomLogger embedded zerolog.Logger
+	copied := *l
+	copied.Info().Msg("info hidden")
+	copied.Warn().Msg("warn shown")
+	l.SetLogger(zerolog.New(&buf).Level(InfoLevel))
+	copied.Info().Msg("info shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || strings.Count(out, "shown") != 2 {
+		t.Errorf("Expected the copy to follow the logger changes, got '%s'", out)
+	}
+	if copied.GetLevel() != InfoLevel {
+		t.Errorf("Expected level %s, got %s", InfoLevel, copied.GetLevel())
+	}
+
+	var zero CustomLogger
+	zero.Error().Msg("discarded")
+}
+
+// TestSetLogLevelWhileLogging is meant for -race: levels are reloaded, like by the config watcher,
+// while other goroutines log
+func TestSetLogLevelWhileLogging(t *testing.T) {
+	previous := Log
+	Log = *NewLogger(zerolog.New(io.Discard))
+	defer func() {
+		Log = previous
+		_ = SetLogLevel("")
+	}()
+
+	component := Component("race")
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					Infof("root")
+					component.Debugf("component")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		spec := "warn,race=debug"
+		if i%2 == 0 {
+			spec = "info"
+		}
+		if err := SetLogLevel(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if GetLevel() != WarnLevel {
+		t.Errorf("Expected the last level %s, got %s", WarnLevel, GetLevel())
+	}
+}
+
+func TestChildLoggerCache(t *testing.T) {
+	var buf bytes.Buffer
+	root := NewLogger(zerolog.New(&buf).Level(WarnLevel))
+	child := root.WithFields(ComponentKey, "cache")
+	defer func() { _ = SetLogLevel("") }()
+
+	first := child.GetLogger()
+	if child.GetLogger() != first {
+		t.Error("Expected the derived logger to be cached")
+	}
+	if err := SetLogLevel("cache=debug"); err != nil {
+		t.Fatal(err)
+	}
+	if child.GetLogger() == first || child.GetLogger().GetLevel() != DebugLevel {
+		t.Errorf("Expected a logger derived again at %s, got %s", DebugLevel, child.GetLogger().GetLevel())
+	}
+	root.SetLogger(zerolog.New(&buf).Level(ErrorLevel).With().Str("root", "new").Logger())
+	child.Debugf("after")
+	if !strings.Contains(buf.String(), `"root":"new"`) {
+		t.Errorf("Expected the child to follow the new root logger, got '%s'", buf.String())
+	}
+}
@@ -1,11 +1,11 @@
 package log
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"sync"
+	"sync/atomic"
 
 	"github.com/rs/zerolog"
 )
@@ -35,94 +35,268 @@ var (
 		"disabled",
 	}
 
-	LogFormats = []string{"console", "json"}
+	// generation is bumped by every logger and level change, invalidating the loggers cached by child loggers
+	generation atomic.Uint64
+
+	// LogFormats lists the registered log formats, the default first, see RegisterLogFormat
+	LogFormats = []string{FormatConsole, FormatJSON, FormatLogfmt, FormatPlain}
 )
 
+// CustomLogger wraps a zerolog logger that can be replaced while other goroutines log through it.
+// Copies of a CustomLogger share the same logger.
+//
+// CustomLogger used to embed zerolog.Logger. The zerolog methods, like Info or With, are still available and log
+// through the current logger; code reading the former Logger field should call GetLogger instead
 type CustomLogger struct {
-	zerolog.Logger
+	*loggerState
+}
+
+type loggerState struct {
+	// logger is the zerolog logger, nil for child loggers until SetLogger is called on them
+	logger atomic.Pointer[zerolog.Logger]
+	// mu serializes the updates of logger, see update
+	mu sync.Mutex
+
+	// parent and fields are set on child loggers, see WithFields
+	parent *CustomLogger
+	fields []interface{}
+	// component is the value of the ComponentKey field, if any
+	component string
+	// derived caches the logger of a child, derived from its parent at some generation
+	derived atomic.Pointer[derivedLogger]
+}
+
+type derivedLogger struct {
+	logger     zerolog.Logger
+	generation uint64
 }
 
 func (l *CustomLogger) Tracef(format string, args ...interface{}) {
-	l.Trace().Msgf(format, args...)
+	l.GetLogger().Trace().Msgf(format, args...)
 }
 func (l *CustomLogger) Debugf(format string, args ...interface{}) {
-	l.Debug().Msgf(format, args...)
+	l.GetLogger().Debug().Msgf(format, args...)
 }
 
 func (l *CustomLogger) Errorf(format string, args ...interface{}) {
-	l.Error().Msgf(format, args...)
+	l.GetLogger().Error().Msgf(format, args...)
 }
 
 func (l *CustomLogger) Warnf(format string, args ...interface{}) {
-	l.Warn().Msgf(format, args...)
+	l.GetLogger().Warn().Msgf(format, args...)
 }
 
 func (l *CustomLogger) Infof(format string, args ...interface{}) {
-	l.Info().Msgf(format, args...)
+	l.GetLogger().Info().Msgf(format, args...)
+}
+
+// NewLogger returns a CustomLogger writing through logger
+func NewLogger(logger zerolog.Logger) *CustomLogger {
+	l := &CustomLogger{&loggerState{}}
+	l.logger.Store(&logger)
+	return l
 }
 
+// SetLogger replaces the underlying zerolog logger, safely while other goroutines log.
+// On a child logger, it stops following the parent
 func (l *CustomLogger) SetLogger(logger zerolog.Logger) {
-	l.Logger = logger
+	if l.loggerState == nil {
+		l.loggerState = &loggerState{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger.Store(&logger)
+	generation.Add(1)
 }
 
+// update replaces the underlying zerolog logger by fn applied to the current one, atomically with other updates
+func (l *CustomLogger) update(fn func(zerolog.Logger) zerolog.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	logger := fn(*l.GetLogger())
+	l.logger.Store(&logger)
+	generation.Add(1)
+}
+
+// GetLogger returns the underlying zerolog logger, which must not be modified. For child loggers,
+// it is derived from the current parent logger, so level and format changes of the parent apply.
+// The derived logger is cached until the next change of a logger or of the levels
 func (l *CustomLogger) GetLogger() *zerolog.Logger {
-	return &l.Logger
+	if l.loggerState == nil {
+		disabled := zerolog.Nop()
+		return &disabled
+	}
+	if logger := l.logger.Load(); logger != nil {
+		return logger
+	}
+	if l.parent == nil {
+		disabled := zerolog.Nop()
+		return &disabled
+	}
+	// load the generation first: a change while deriving leaves a stale generation, so the next call derives again
+	gen := generation.Load()
+	if derived := l.derived.Load(); derived != nil && derived.generation == gen {
+		return &derived.logger
+	}
+	derived := &derivedLogger{logger: l.parent.GetLogger().With().Fields(l.fields).Logger(), generation: gen}
+	if level, ok := ComponentLevel(l.component); l.component != "" && ok {
+		derived.logger = derived.logger.Level(level)
+	}
+	l.derived.Store(derived)
+	return &derived.logger
+}
+
+// WithFields returns a child logger adding the key value pairs to every message,
+// like l.WithFields("component", "search").
+// A ComponentKey value makes the child honor the level of that component, see SetLogLevel
+func (l *CustomLogger) WithFields(keyValues ...interface{}) *CustomLogger {
+	child := &CustomLogger{&loggerState{parent: l, fields: keyValues, component: l.component}}
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if name, ok := keyValues[i+1].(string); ok && keyValues[i] == ComponentKey {
+			child.component = name
+		}
+	}
+	return child
+}
+
+// With creates a zerolog child logger context from the current logger, like zerolog.Logger.With.
+// The resulting logger does not follow later changes of l, see WithFields for one that does
+func (l *CustomLogger) With() zerolog.Context {
+	return l.GetLogger().With()
+}
+
+// Trace starts a new message with trace level, like zerolog.Logger.Trace
+func (l *CustomLogger) Trace() *zerolog.Event {
+	return l.GetLogger().Trace()
+}
+
+// Debug starts a new message with debug level, like zerolog.Logger.Debug
+func (l *CustomLogger) Debug() *zerolog.Event {
+	return l.GetLogger().Debug()
+}
+
+// Info starts a new message with info level, like zerolog.Logger.Info
+func (l *CustomLogger) Info() *zerolog.Event {
+	return l.GetLogger().Info()
+}
+
+// Warn starts a new message with warn level, like zerolog.Logger.Warn
+func (l *CustomLogger) Warn() *zerolog.Event {
+	return l.GetLogger().Warn()
+}
+
+// Error starts a new message with error level, like zerolog.Logger.Error
+func (l *CustomLogger) Error() *zerolog.Event {
+	return l.GetLogger().Error()
+}
+
+// Err starts a new message with error level with err as a field if not nil, like zerolog.Logger.Err
+func (l *CustomLogger) Err(err error) *zerolog.Event {
+	return l.GetLogger().Err(err)
+}
+
+// Fatal starts a new message with fatal level, like zerolog.Logger.Fatal
+func (l *CustomLogger) Fatal() *zerolog.Event {
+	return l.GetLogger().Fatal()
+}
+
+// Panic starts a new message with panic level, like zerolog.Logger.Panic
+func (l *CustomLogger) Panic() *zerolog.Event {
+	return l.GetLogger().Panic()
+}
+
+// WithLevel starts a new message with level, like zerolog.Logger.WithLevel
+func (l *CustomLogger) WithLevel(level zerolog.Level) *zerolog.Event {
+	return l.GetLogger().WithLevel(level)
+}
+
+// Log starts a new message with no level, like zerolog.Logger.Log
+func (l *CustomLogger) Log() *zerolog.Event {
+	return l.GetLogger().Log()
+}
+
+// Print sends a log event at debug level, like zerolog.Logger.Print
+func (l *CustomLogger) Print(v ...interface{}) {
+	l.GetLogger().Print(v...)
+}
+
+// Printf sends a log event at debug level, like zerolog.Logger.Printf
+func (l *CustomLogger) Printf(format string, v ...interface{}) {
+	l.GetLogger().Printf(format, v...)
+}
+
+// Write implements io.Writer, like zerolog.Logger.Write
+func (l *CustomLogger) Write(p []byte) (int, error) {
+	return l.GetLogger().Write(p)
+}
+
+// GetLevel returns the current level of l
+func (l *CustomLogger) GetLevel() zerolog.Level {
+	return l.GetLogger().GetLevel()
+}
+
+// Level returns a copy of the current logger with the minimum level set, like zerolog.Logger.Level
+func (l *CustomLogger) Level(level zerolog.Level) zerolog.Logger {
+	return l.GetLogger().Level(level)
+}
+
+// Output returns a copy of the current logger writing to w, like zerolog.Logger.Output
+func (l *CustomLogger) Output(w io.Writer) zerolog.Logger {
+	return l.GetLogger().Output(w)
 }
 
 func GetLevel() zerolog.Level {
 	return Log.GetLogger().GetLevel()
 }
 
+// NewDefaultLogger returns a logger writing to the log output, which follows SetLogFormat and SetLogFile
 func NewDefaultLogger() CustomLogger {
-	output := zerolog.ConsoleWriter{
-		Out:        PreferredWriter(),
-		TimeFormat: time.RFC3339,
-	}
-	return CustomLogger{
-		zerolog.New(output).
-			With().
-			Timestamp().
-			Logger(),
-	}
+	outputMu.Lock()
+	switchOutput()
+	outputMu.Unlock()
+	return *NewLogger(zerolog.New(output).
+		With().
+		Timestamp().
+		Logger())
 }
 
 func IsValidLogFormat(format string) error {
-	for _, f := range LogFormats {
-		if format == f {
-			return nil
-		}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	if _, ok := formatWriters[format]; ok {
+		return nil
 	}
 	return fmt.Errorf("invalid log format '%s'. Provide one of: %v", format, LogFormats)
 }
 
+// SetLogFormat switches the output of Log, and of its child loggers, to a registered log format
 func SetLogFormat(format string) error {
 	err := IsValidLogFormat(format)
 	if err != nil {
 		return err
 	}
-	switch format {
-	// This is the current default
-	// case "console":
-	case "json":
-		Log.SetLogger(Log.GetLogger().Output(PreferredWriter()))
-	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	logFormat = format
+	switchOutput()
+	Log.update(func(logger zerolog.Logger) zerolog.Logger { return logger.Output(output) })
 	return nil
 }
 
+// PreferredWriter returns the log destinations: stderr and, if set, the log file, see SetLogFile.
+// The writer follows later changes of the destinations
 func PreferredWriter() io.Writer {
-	return io.MultiWriter(
-		new(bytes.Buffer),
-		os.Stderr,
-	)
+	return destinations
 }
 
-func SetLogLevel(format string) error {
-	parsedLevel, err := ParseLevel(format)
-	if err != nil {
-		return err
+func preferredWriter() io.Writer {
+	switch {
+	case logFile == nil:
+		return os.Stderr
+	case logStderr:
+		return io.MultiWriter(os.Stderr, logFile)
 	}
-	Log.SetLogger(Log.GetLogger().Level(parsedLevel))
-	return nil
+	return logFile
 }
 
 func Trace(i ...interface{}) {
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	// outputMu guards the log destinations and format
+	outputMu  sync.Mutex
+	logFormat = LogFormats[0]
+	logFile   *RotatingFile
+	logStderr = true
+
+	reopenOnSignal sync.Once
+
+	// output is the formatted log output of the default loggers and destinations the raw one, see PreferredWriter.
+	// They switch writers without replacing the loggers holding them
+	output       = &switchWriter{}
+	destinations = &switchWriter{}
+)
+
+// SetLogFile writes the log to the file described by opts, also to stderr if stderr is true.
+// An empty opts.Path stops writing to a file. The file is kept open if opts did not change,
+// and reopened on SIGHUP, see ReopenLogFile
+func SetLogFile(opts FileOptions, stderr bool) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	previous := logFile
+	switch {
+	case opts.Path == "":
+		logFile = nil
+	case logFile == nil || logFile.Options() != opts:
+		f, err := OpenRotatingFile(opts)
+		if err != nil {
+			return err
+		}
+		logFile = f
+		reopenOnSignal.Do(func() {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGHUP)
+			go func() {
+				for range signals {
+					if err := ReopenLogFile(); err != nil {
+						Errorf("failed to reopen log file: %s", err)
+					}
+				}
+			}()
+		})
+	}
+	logStderr = stderr
+	switchOutput()
+	Log.update(func(logger zerolog.Logger) zerolog.Logger { return logger.Output(output) })
+
+	// no write can reach the previous file anymore, as switching waits for the writes in progress
+	if previous != nil && previous != logFile {
+		return previous.Close()
+	}
+	return nil
+}
+
+// ReopenLogFile closes and opens again the log file, if any, after it was moved by an external tool like logrotate
+func ReopenLogFile() error {
+	outputMu.Lock()
+	f := logFile
+	outputMu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f.Reopen()
+}
+
+// switchOutput points output and destinations to the current log destinations and format. outputMu must be held
+func switchOutput() {
+	output.set(formattedOutput())
+	destinations.set(preferredWriter())
+}
+
+// formattedOutput returns the log destinations wrapped in the writer of the log format.
+// Colors are only written to stderr
+func formattedOutput() io.Writer {
+	formatWriter := formatWriters[logFormat]
+	writers := make([]io.Writer, 0, 2)
+	if logFile == nil || logStderr {
+		writers = append(writers, formatWriter(os.Stderr, true))
+	}
+	if logFile != nil {
+		writers = append(writers, formatWriter(logFile, false))
+	}
+	if len(writers) == 1 {
+		return writers[0]
+	}
+	return zerolog.MultiLevelWriter(writers...)
+}
+
+// switchWriter is a zerolog.LevelWriter whose writer can be replaced while other goroutines write
+type switchWriter struct {
+	mu sync.RWMutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.w.Write(p)
+}
+
+func (s *switchWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if lw, ok := s.w.(zerolog.LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return s.w.Write(p)
+}
+
+// set replaces the writer, once the writes in progress are done
+func (s *switchWriter) set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LevelTrace is the slog level mapped to TraceLevel, below slog.LevelDebug
+const LevelTrace = slog.Level(-8)
+
+// SlogHandler is a slog.Handler writing records through a CustomLogger, so they share its level and format
+type SlogHandler struct {
+	logger *CustomLogger
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler writing through l. If l is nil, records go to the logger of their context,
+// see FromContext, which defaults to Log
+func NewSlogHandler(l *CustomLogger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// SetSlogDefault installs a SlogHandler writing to the context logger as slog.Default(),
+// which also routes the standard log package through Log
+func SetSlogDefault() {
+	slog.SetDefault(slog.New(NewSlogHandler(nil)))
+}
+
+// SlogLevel maps a slog level to a zerolog level: below slog.LevelDebug is TraceLevel,
+// above slog.LevelError is still ErrorLevel
+func SlogLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	default:
+		return ErrorLevel
+	}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	l := SlogLevel(level)
+	return l >= h.loggerFor(ctx).GetLogger().GetLevel() && l >= zerolog.GlobalLevel()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	event := h.loggerFor(ctx).GetLogger().WithLevel(SlogLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+	for _, a := range h.attrs {
+		event = addSlogAttr(event, "", a)
+	}
+	prefix := h.groupPrefix()
+	record.Attrs(func(a slog.Attr) bool {
+		event = addSlogAttr(event, prefix, a)
+		return true
+	})
+	event.Msg(record.Message)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	child := *h
+	child.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	if prefix := h.groupPrefix(); prefix != "" {
+		// attrs belong to the open groups
+		child.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)],
+			slog.Attr{Key: strings.TrimSuffix(prefix, "."), Value: slog.GroupValue(attrs...)})
+	}
+	return &child
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &child
+}
+
+func (h *SlogHandler) loggerFor(ctx context.Context) *CustomLogger {
+	if h.logger != nil {
+		return h.logger
+	}
+	return FromContext(ctx)
+}
+
+// groupPrefix returns the dotted prefix of the keys in the open groups
+func (h *SlogHandler) groupPrefix() string {
+	prefix := ""
+	for _, g := range h.groups {
+		prefix += g + "."
+	}
+	return prefix
+}
+
+// addSlogAttr adds a to event, with the dotted key of its group
+func addSlogAttr(event *zerolog.Event, prefix string, a slog.Attr) *zerolog.Event {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return event
+	}
+	key, value := prefix+a.Key, a.Value
+	switch value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = key + "."
+		}
+		for _, ga := range value.Group() {
+			event = addSlogAttr(event, groupPrefix, ga)
+		}
+		return event
+	case slog.KindString:
+		return event.Str(key, value.String())
+	case slog.KindInt64:
+		return event.Int64(key, value.Int64())
+	case slog.KindUint64:
+		return event.Uint64(key, value.Uint64())
+	case slog.KindFloat64:
+		return event.Float64(key, value.Float64())
+	case slog.KindBool:
+		return event.Bool(key, value.Bool())
+	case slog.KindDuration:
+		return event.Dur(key, value.Duration())
+	case slog.KindTime:
+		return event.Str(key, value.Time().Format(time.RFC3339Nano))
+	}
+	if err, ok := value.Any().(error); ok {
+		return event.AnErr(key, err)
+	}
+	return event.Interface(key, value.Any())
+}
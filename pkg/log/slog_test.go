@@ -0,0 +1,69 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestSlogLevel(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected zerolog.Level
+	}{
+		{LevelTrace, TraceLevel},
+		{slog.LevelDebug, DebugLevel},
+		{slog.LevelInfo, InfoLevel},
+		{slog.LevelInfo + 1, InfoLevel},
+		{slog.LevelWarn, WarnLevel},
+		{slog.LevelError, ErrorLevel},
+		{slog.LevelError + 4, ErrorLevel},
+	}
+	for _, tt := range tests {
+		if l := SlogLevel(tt.level); l != tt.expected {
+			t.Errorf("Expected %s to map to %s, got %s", tt.level, tt.expected, l)
+		}
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(zerolog.New(&buf).Level(TraceLevel))
+	logger := slog.New(NewSlogHandler(l)).With("component", "test").WithGroup("req")
+
+	logger.Log(context.Background(), LevelTrace, "traced", "id", 42, slog.Group("user", "name", "x"),
+		"err", errors.New("boom"))
+	var event map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Expected a JSON event, got '%s': %s", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"level": "trace", "message": "traced", "component": "test",
+		"req.id": float64(42), "req.user.name": "x", "req.err": "boom",
+	}
+	for k, v := range expected {
+		if event[k] != v {
+			t.Errorf("Expected %s to be %v, got %v in %s", k, v, event[k], buf.String())
+		}
+	}
+
+	l.SetLogger(l.GetLogger().Level(WarnLevel))
+	buf.Reset()
+	logger.Info("filtered")
+	if buf.Len() > 0 {
+		t.Errorf("Expected info to be filtered at warn level, got '%s'", buf.String())
+	}
+
+	// without a logger, the context logger is used
+	ctx := WithContext(context.Background(), NewLogger(zerolog.New(&buf)))
+	slog.New(NewSlogHandler(nil)).WarnContext(ctx, "from context")
+	if !strings.Contains(buf.String(), "from context") {
+		t.Errorf("Expected the context logger to be used, got '%s'", buf.String())
+	}
+}
@@ -1,6 +1,7 @@
 package reflectutil
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 )
@@ -38,3 +39,44 @@ func GetStructValue(aStruct any, fieldName string) any {
 	}
 	return nil
 }
+
+// WalkStructFields calls fn for every exported field of the struct pointed to by aStruct.
+//
+// Struct fields for which descend returns true are walked into instead of being passed to fn,
+// parents holds the enclosing struct fields from the outermost one. A nil descend walks into all structs.
+func WalkStructFields(
+	aStruct any,
+	descend func(field reflect.StructField) bool,
+	fn func(parents []reflect.StructField, field reflect.StructField, value reflect.Value) error,
+) error {
+	ref := reflect.ValueOf(aStruct)
+	if ref.Kind() != reflect.Pointer || ref.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected a pointer to struct, got %T", aStruct)
+	}
+	return walkStructFields(ref.Elem(), nil, descend, fn)
+}
+
+func walkStructFields(
+	ref reflect.Value,
+	parents []reflect.StructField,
+	descend func(field reflect.StructField) bool,
+	fn func(parents []reflect.StructField, field reflect.StructField, value reflect.Value) error,
+) error {
+	for i := 0; i < ref.NumField(); i++ {
+		field := ref.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := ref.Field(i)
+		if field.Type.Kind() == reflect.Struct && (descend == nil || descend(field)) {
+			if err := walkStructFields(value, append(parents[:len(parents):len(parents)], field), descend, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(parents, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
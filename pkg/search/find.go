@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/thedataflows/go-commons/pkg/log"
 	"github.com/thedataflows/go-commons/pkg/stringutil"
 )
 
@@ -72,7 +73,12 @@ func (f *JustLister) ProcessFile(ctx context.Context, filePath string, resultsCh
 }
 
 // FindFile walks through the directory, calling ProcessFile for each file.
+// It logs through the logger of ctx, see log.FromContext, which is passed on to ProcessFile.
 func FindFile(ctx context.Context, startDir string, fileFilter FileFilter, finder Finder, maxWorkers int) *Results {
+	logger := log.FromContext(ctx).WithFields("component", "search")
+	ctx = log.WithContext(ctx, logger)
+	logger.Debugf("searching '%s' with %d workers", startDir, maxWorkers)
+
 	results := Results{}
 	resultsChan := make(chan *Results, maxWorkers)
 	var wg sync.WaitGroup
@@ -92,6 +98,7 @@ func FindFile(ctx context.Context, startDir string, fileFilter FileFilter, finde
 		}
 		dirEntries, err := os.ReadDir(path)
 		if err != nil {
+			logger.Debugf("cannot read directory '%s': %s", path, err)
 			resultsChan <- &Results{
 				Results: []Result{
 					NewResult("", 0, path, err, false),
@@ -114,6 +121,7 @@ func FindFile(ctx context.Context, startDir string, fileFilter FileFilter, finde
 			}
 			select {
 			case <-ctx.Done():
+				logger.Debugf("search of '%s' canceled: %s", startDir, ctx.Err())
 				return ctx.Err()
 			case sem <- struct{}{}:
 				// Wait for an available worker from the pool.
@@ -152,6 +160,7 @@ func FindFile(ctx context.Context, startDir string, fileFilter FileFilter, finde
 	for fileResults := range resultsChan {
 		results.Results = append(results.Results, fileResults.Results...)
 	}
+	logger.Debugf("search of '%s' done with %d results", startDir, len(results.Results))
 
 	return &results
 }
@@ -7,12 +7,17 @@
 package search
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"syscall"
 	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/thedataflows/go-commons/pkg/log"
 )
 
 type testCase struct {
@@ -92,3 +97,19 @@ func TestProcessFile(t *testing.T) {
 		}
 	}
 }
+
+func TestFindFileContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.NewLogger(zerolog.New(&buf).Level(zerolog.DebugLevel))
+	ctx := log.WithContext(context.Background(), logger.WithFields("request", "42"))
+
+	results := FindFile(ctx, ".", nil, &TextFinder{Text: []byte("abc")}, 2)
+	if len(results.Results) == 0 {
+		t.Fatal("Expected results, got none")
+	}
+	for _, s := range []string{`"component":"search"`, `"request":"42"`, "searching '.' with 2 workers"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("Expected the context logger output to contain '%s', got:\n%s", s, buf.String())
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package stringutil
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 // ConcatStrings returns concatenated strings
 func ConcatStrings(strs ...string) string {
@@ -10,3 +13,23 @@ func ConcatStrings(strs ...string) string {
 	}
 	return strBuilder.String()
 }
+
+// ToKebabCase converts a CamelCase identifier to kebab-case, keeping acronyms together: HTTPPort -> http-port
+func ToKebabCase(s string) string {
+	runes := []rune(s)
+	var strBuilder strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				strBuilder.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		if r == '_' || r == ' ' {
+			r = '-'
+		}
+		strBuilder.WriteRune(r)
+	}
+	return strBuilder.String()
+}